@@ -34,3 +34,11 @@ func DrawPoly(gc *draw2dimg.GraphicContext, p *geom.Polygon, sx float64, sy floa
 	gc.SetMatrixTransform(originalMatrix)
 
 }
+
+// StrokePolyRings strokes the outer ring and every hole of p.
+func StrokePolyRings(gc *draw2dimg.GraphicContext, p *geom.Polygon, sx float64, sy float64) {
+	for i := 0; i < p.NumLinearRings(); i++ {
+		ring := p.LinearRing(i)
+		DrawPoly(gc, geom.NewPolygonFlat(geom.XY, ring.FlatCoords(), []int{len(ring.FlatCoords())}), sx, sy, false)
+	}
+}
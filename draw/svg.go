@@ -0,0 +1,41 @@
+package draw
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/twpayne/go-geom"
+)
+
+// SvgPath returns the path data of every ring of p, to be filled with fill-rule="evenodd"
+// so that holes are left empty.
+func SvgPath(p *geom.Polygon) string {
+	sb := strings.Builder{}
+
+	for _, ring := range p.Coords() {
+		for i, pt := range ring {
+			if i == 0 {
+				sb.WriteString("M")
+			} else {
+				sb.WriteString(" L")
+			}
+			fmt.Fprintf(&sb, "%.3f %.3f", pt.X(), pt.Y())
+		}
+		sb.WriteString(" Z ")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// SvgColor returns the hex representation of c and its opacity in [0, 1].
+func SvgColor(c color.Color) (string, float64) {
+	r, g, b, a := c.RGBA()
+
+	if a == 0 {
+		return "#000000", 0
+	}
+
+	// RGBA returns alpha-premultiplied values
+	return fmt.Sprintf("#%02x%02x%02x", r*0xff/a, g*0xff/a, b*0xff/a), float64(a) / 0xffff
+}
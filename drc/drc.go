@@ -0,0 +1,151 @@
+package drc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"genetic_pcb/pcb"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Report lists the design rule violations of a pcb, as found by the same checks the evaluation uses.
+type Report struct {
+	Violations []pcb.Violation
+}
+
+// Check computes the geometry of p if needed and returns its violations.
+func Check(pgo *pcb.PcbGeneticOperators, p *pcb.Pcb) *Report {
+	if p.Geometry == nil {
+		p.ComputeGeometryWithParams(pgo.GeometryParams())
+	}
+
+	return &Report{Violations: pgo.CheckDesignRules(p)}
+}
+
+// Cost returns the total cost of the violations.
+func (r *Report) Cost() float64 {
+	cost := 0.0
+
+	for _, v := range r.Violations {
+		cost += v.Cost
+	}
+
+	return cost
+}
+
+func (r *Report) Count(kind pcb.ViolationKind) int {
+	res := 0
+
+	for _, v := range r.Violations {
+		if v.Kind == kind {
+			res++
+		}
+	}
+
+	return res
+}
+
+func objectNames(objects []pcb.Object) []string {
+	res := make([]string, len(objects))
+
+	for i, o := range objects {
+		res[i] = o.String()
+	}
+
+	return res
+}
+
+// WriteText writes one line per violation, after a summary line.
+func (r *Report) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "%d violations, cost %.3f\n", len(r.Violations), r.Cost())
+
+	for _, v := range r.Violations {
+		fmt.Fprintf(bw, "%v: %s at (%.3f, %.3f)", v.Kind, strings.Join(objectNames(v.Objects), ", "), v.X, v.Y)
+
+		if v.Required > 0 {
+			fmt.Fprintf(bw, ", distance %.3f < %.3f", v.Measured, v.Required)
+		}
+
+		fmt.Fprintf(bw, ", cost %.3f\n", v.Cost)
+	}
+
+	return bw.Flush()
+}
+
+type jsonObject struct {
+	Kind  string `json:"kind"`
+	Index int    `json:"index"`
+}
+
+type jsonViolation struct {
+	Kind     string       `json:"kind"`
+	Objects  []jsonObject `json:"objects"`
+	X        float64      `json:"x"`
+	Y        float64      `json:"y"`
+	Measured float64      `json:"measured,omitempty"`
+	Required float64      `json:"required,omitempty"`
+	Cost     float64      `json:"cost"`
+}
+
+type jsonReport struct {
+	Cost       float64         `json:"cost"`
+	Counts     map[string]int  `json:"counts"`
+	Violations []jsonViolation `json:"violations"`
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	res := jsonReport{
+		Cost:       r.Cost(),
+		Counts:     make(map[string]int),
+		Violations: make([]jsonViolation, len(r.Violations)),
+	}
+
+	for i, v := range r.Violations {
+		objects := make([]jsonObject, len(v.Objects))
+		for j, o := range v.Objects {
+			objects[j] = jsonObject{Kind: o.Kind.String(), Index: o.Index}
+		}
+
+		res.Counts[v.Kind.String()]++
+		res.Violations[i] = jsonViolation{
+			Kind:     v.Kind.String(),
+			Objects:  objects,
+			X:        v.X,
+			Y:        v.Y,
+			Measured: v.Measured,
+			Required: v.Required,
+			Cost:     v.Cost,
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(res)
+}
+
+// Save writes the report as JSON if path ends in .json, as text otherwise.
+func (r *Report) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		err = r.WriteJSON(f)
+	} else {
+		err = r.WriteText(f)
+	}
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
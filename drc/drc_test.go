@@ -0,0 +1,95 @@
+package drc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"genetic_pcb/drc"
+	"genetic_pcb/pcb"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		OutOfBoundsCost:           100,
+		UnconnectedCost:           10,
+		MinDist:                   2,
+	})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 50, Component: 1},
+			{X: 90, Y: 50, Component: 2},
+			{X: 50, Y: 10, Component: 3},
+			{X: 50, Y: 90, Component: 4},
+			{X: 10, Y: 90, Component: 5},
+			{X: 90, Y: 90, Component: 6},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}, {Nodes: []int{4, 5}}},
+		Components: []pcb.Component{
+			{CX: -50, CY: 50, X1: -5, Y1: -5, X2: 5, Y2: 5},
+		},
+	})
+
+	report := drc.Check(pgo, p)
+
+	expected := map[pcb.ViolationKind]int{
+		pcb.SHORT_VIOLATION:        1,
+		pcb.OUT_OF_BOARD_VIOLATION: 1,
+		pcb.UNCONNECTED_VIOLATION:  1,
+	}
+
+	for kind, n := range expected {
+		if report.Count(kind) != n {
+			t.Errorf("Expected %d %v violations, got %d", n, kind, report.Count(kind))
+		}
+	}
+
+	if len(report.Violations) != 3 {
+		t.Errorf("Expected 3 violations, got %v", report.Violations)
+	}
+
+	// The report and the evaluation must agree
+	cost := pgo.EvaluatePcbIntersections(p) + pgo.EvaluateComponentsOutOfBounds(p) + pgo.EvaluateKeepouts(p) + pgo.EvaluateUnconnectedNets(p)
+	if report.Cost() != cost || cost != 111 {
+		t.Errorf("Expected a cost of 111, report has %v and evaluation %v", report.Cost(), cost)
+	}
+
+	text := bytes.Buffer{}
+	if err := report.WriteText(&text); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(text.String(), "short: edge 0, edge 1 at (50.000, 50.000)") {
+		t.Errorf("Unexpected text report:\n%s", text.String())
+	}
+
+	buf := bytes.Buffer{}
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		Counts     map[string]int `json:"counts"`
+		Violations []struct {
+			Kind    string `json:"kind"`
+			Objects []struct {
+				Kind  string `json:"kind"`
+				Index int    `json:"index"`
+			} `json:"objects"`
+		} `json:"violations"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+
+	if decoded.Counts["unconnected"] != 1 || len(decoded.Violations) != 3 {
+		t.Errorf("Unexpected JSON report: %s", buf.String())
+	}
+}
@@ -24,6 +24,15 @@ type GeneticAlgorithm[T fmt.Stringer] struct {
 	parallelism                      int
 	selfReproductionProb             float64
 	randomGenerator                  *rand.Rand
+	generation                       int
+}
+
+// Statistics summarizes the current population.
+type Statistics[T any] struct {
+	Generation   int
+	Best         IndividualWithFitness[T]
+	MeanFitness  float64
+	WorstFitness float64
 }
 
 func NewGeneticAlgorithm[T fmt.Stringer](initialPop []T, elitarismKeepN int, pOfSelectingSecondParentRandomly float64, geneticOperators GeneticOperators[T], parallelism int, selfReproductionProb float64) *GeneticAlgorithm[T] {
@@ -162,7 +171,33 @@ func (ga *GeneticAlgorithm[T]) ComputeNextGeneration() {
 	}
 
 	ga.CurrentPop = append(toBeKept, generated...)
+	ga.generation++
 
 	ga.sortPop()
 
 }
+
+// Statistics returns the statistics of the current population.
+func (ga *GeneticAlgorithm[T]) Statistics() Statistics[T] {
+	res := Statistics[T]{
+		Generation:   ga.generation,
+		Best:         ga.CurrentPop[0],
+		WorstFitness: ga.CurrentPop[len(ga.CurrentPop)-1].Fitness,
+	}
+
+	for _, ind := range ga.CurrentPop {
+		res.MeanFitness += ind.Fitness / float64(len(ga.CurrentPop))
+	}
+
+	return res
+}
+
+// Explain explains the fitness of the best individual, it returns nil unless the genetic operators
+// are an Explainer. Explaining usually costs more than evaluating, so it is left to the callers.
+func (ga *GeneticAlgorithm[T]) Explain() fmt.Stringer {
+	if explainer, ok := ga.geneticOperators.(Explainer[T]); ok {
+		return explainer.Explain(ga.CurrentPop[0].Individual, NewGeneticContext())
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package genetic
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -24,6 +25,11 @@ type IndividualEvaluator[T any] interface {
 	Evaluate(i T, c *GeneticContext) float64
 }
 
+// Explainer is implemented by evaluators able to detail how they evaluate an individual.
+type Explainer[T any] interface {
+	Explain(i T, c *GeneticContext) fmt.Stringer
+}
+
 type CrossoverManager[T any] interface {
 	CrossOver(i1 T, i2 T, c *GeneticContext) T
 }
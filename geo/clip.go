@@ -0,0 +1,252 @@
+package geo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/twpayne/go-geom"
+)
+
+// Tolerance on coordinates when ordering the segments of a slab
+const clipEpsilon = 1e-9
+
+// clipSegment is a non horizontal segment of a ring, going up from y1 to y2.
+type clipSegment struct {
+	x1, y1, x2, y2 float64
+	// owner is the index of the polygon in the inside polygons followed by the outside ones
+	owner int
+	id    int
+}
+
+func (s *clipSegment) xAt(y float64) float64 {
+	return s.x1 + (s.x2-s.x1)*(y-s.y1)/(s.y2-s.y1)
+}
+
+// crossingY returns the y where the lines of the two segments cross, or +Inf if they are parallel.
+func crossingY(a, b *clipSegment) float64 {
+	da := (a.x2 - a.x1) / (a.y2 - a.y1)
+	db := (b.x2 - b.x1) / (b.y2 - b.y1)
+
+	if math.Abs(da-db) < clipEpsilon {
+		return math.Inf(1)
+	}
+
+	return (b.x1 - a.x1 + da*a.y1 - db*b.y1) / (da - db)
+}
+
+// openTrapezoid is a trapezoid of the result between two segments, still growing upwards.
+type openTrapezoid struct {
+	left, right *clipSegment
+	bottom, top float64
+}
+
+func (t *openTrapezoid) polygon() *geom.Polygon {
+	flatCoords := []float64{t.left.xAt(t.bottom), t.bottom, t.right.xAt(t.bottom), t.bottom}
+
+	// Triangles have a single point on one of the horizontal sides
+	if rx, lx := t.right.xAt(t.top), t.left.xAt(t.top); rx-lx > clipEpsilon {
+		flatCoords = append(flatCoords, rx, t.top, lx, t.top)
+	} else {
+		flatCoords = append(flatCoords, lx, t.top)
+	}
+
+	if flatCoords[2]-flatCoords[0] <= clipEpsilon {
+		flatCoords = flatCoords[2:]
+	}
+
+	flatCoords = append(flatCoords, flatCoords[0], flatCoords[1])
+
+	return geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+}
+
+// Clip returns the region inside all the inside polygons and outside all the outside ones, split in
+// trapezoids with two horizontal sides. Polygons follow the even-odd rule, so their holes are outside
+// of them, and they can overlap each other.
+//
+// The plane is swept bottom up in slabs bounded by the vertices and the crossings of the segments,
+// where the region is a set of intervals between segments. Intervals between the same segments in
+// consecutive slabs are merged into a single trapezoid.
+func Clip(inside, outside []*geom.Polygon) []*geom.Polygon {
+	segments := make([]*clipSegment, 0)
+	ys := make([]float64, 0)
+
+	for owner, p := range append(append([]*geom.Polygon{}, inside...), outside...) {
+		for r := 0; r < p.NumLinearRings(); r++ {
+			coords := p.LinearRing(r).Coords()
+
+			for i := 1; i < len(coords); i++ {
+				a, b := coords[i-1], coords[i]
+				ys = append(ys, a[1])
+
+				if a[1] == b[1] {
+					continue
+				}
+
+				if a[1] > b[1] {
+					a, b = b, a
+				}
+
+				segments = append(segments, &clipSegment{a[0], a[1], b[0], b[1], owner, len(segments)})
+			}
+		}
+	}
+
+	if len(inside) == 0 || len(segments) == 0 {
+		return nil
+	}
+
+	sort.Float64s(ys)
+	sort.Slice(segments, func(i, j int) bool { return segments[i].y1 < segments[j].y1 })
+
+	res := make([]*geom.Polygon, 0)
+	parity := make([]bool, len(inside)+len(outside))
+	active := make([]*clipSegment, 0)
+	open := make(map[[2]int]*openTrapezoid)
+	next := 0
+
+	closeAll := func(keep map[[2]int]*openTrapezoid) {
+		for key, t := range open {
+			if _, ok := keep[key]; !ok {
+				res = append(res, t.polygon())
+			}
+		}
+	}
+
+	for k := 0; k < len(ys)-1; k++ {
+		y, yEnd := ys[k], ys[k+1]
+		if yEnd <= y {
+			continue
+		}
+
+		// Update the segments spanning the slab
+		kept := active[:0]
+		for _, s := range active {
+			if s.y2 > y {
+				kept = append(kept, s)
+			}
+		}
+		active = kept
+
+		for next < len(segments) && segments[next].y1 <= y {
+			if segments[next].y2 > y {
+				active = append(active, segments[next])
+			}
+			next++
+		}
+
+		for y < yEnd {
+			top := yEnd
+			sort.Slice(active, func(i, j int) bool {
+				xi, xj := active[i].xAt(y), active[j].xAt(y)
+				if math.Abs(xi-xj) > clipEpsilon {
+					return xi < xj
+				}
+				return active[i].xAt(top) < active[j].xAt(top)
+			})
+
+			// The first crossing is between two segments that are next to each other until then
+			for j := 1; j < len(active); j++ {
+				if active[j-1].xAt(top) > active[j].xAt(top)+clipEpsilon {
+					if yc := crossingY(active[j-1], active[j]); yc > y+clipEpsilon && yc < top {
+						top = yc
+					}
+				}
+			}
+
+			intervals := make(map[[2]int]*openTrapezoid)
+			insideCount, outsideCount := 0, 0
+
+			for i := range parity {
+				parity[i] = false
+			}
+
+			for j, s := range active {
+				parity[s.owner] = !parity[s.owner]
+
+				delta := 1
+				if !parity[s.owner] {
+					delta = -1
+				}
+
+				if s.owner < len(inside) {
+					insideCount += delta
+				} else {
+					outsideCount += delta
+				}
+
+				if insideCount < len(inside) || outsideCount > 0 || j+1 == len(active) {
+					continue
+				}
+
+				left, right := s, active[j+1]
+				if right.xAt(y)-left.xAt(y) <= clipEpsilon && right.xAt(top)-left.xAt(top) <= clipEpsilon {
+					continue
+				}
+
+				key := [2]int{left.id, right.id}
+				if t, ok := open[key]; ok {
+					t.top = top
+					intervals[key] = t
+				} else {
+					intervals[key] = &openTrapezoid{left, right, y, top}
+				}
+			}
+
+			closeAll(intervals)
+			open = intervals
+			y = top
+		}
+	}
+
+	closeAll(nil)
+
+	return res
+}
+
+// OffsetConvex returns the convex polygon grown by d on every side, with mitered corners.
+func OffsetConvex(p *geom.Polygon, d float64) *geom.Polygon {
+	points := dedupPolyline(p.LinearRing(0).Coords())
+	if len(points) > 1 && points[0][0] == points[len(points)-1][0] && points[0][1] == points[len(points)-1][1] {
+		points = points[:len(points)-1]
+	}
+
+	n := len(points)
+	if n < 3 || d == 0 {
+		return p
+	}
+
+	area := 0.0
+	for i := range points {
+		a, b := points[i], points[(i+1)%n]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+
+	// Outward normal of the edge starting at point i
+	normal := func(i int) (float64, float64) {
+		nx, ny := leftNormal(points[i], points[(i+1)%n])
+		if area > 0 {
+			return -nx, -ny
+		}
+
+		return nx, ny
+	}
+
+	flatCoords := make([]float64, 0, 2*n+2)
+
+	for i := range points {
+		n1x, n1y := normal((i + n - 1) % n)
+		n2x, n2y := normal(i)
+		k := 1 + n1x*n2x + n1y*n2y
+
+		mx, my := n1x*d, n1y*d
+		if k > clipEpsilon {
+			mx, my = (n1x+n2x)*d/k, (n1y+n2y)*d/k
+		}
+
+		flatCoords = append(flatCoords, points[i][0]+mx, points[i][1]+my)
+	}
+
+	flatCoords = append(flatCoords, flatCoords[0], flatCoords[1])
+
+	return geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+}
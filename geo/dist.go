@@ -1,6 +1,8 @@
 package geo
 
 import (
+	"math"
+
 	"github.com/twpayne/go-geom"
 	"github.com/twpayne/go-geom/xy"
 )
@@ -113,3 +115,18 @@ func PolyDistance(p1 *geom.Polygon, p2 *geom.Polygon) float64 {
 
 	return min
 }
+
+// ClosestPointOnSegment returns the point of the segment x1, y1 - x2, y2 closest to x, y.
+func ClosestPointOnSegment(x, y, x1, y1, x2, y2 float64) (float64, float64) {
+	dx, dy := x2-x1, y2-y1
+	lenSq := dx*dx + dy*dy
+
+	if lenSq == 0 {
+		return x1, y1
+	}
+
+	t := ((x-x1)*dx + (y-y1)*dy) / lenSq
+	t = math.Max(0, math.Min(1, t))
+
+	return x1 + t*dx, y1 + t*dy
+}
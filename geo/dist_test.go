@@ -2,6 +2,8 @@ package geo_test
 
 import (
 	"genetic_pcb/geo"
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/twpayne/go-geom"
@@ -50,3 +52,174 @@ func TestPolyDistance4(t *testing.T) {
 		t.Errorf("Expected distance = 1.1, got %v", dist)
 	}
 }
+
+func TestIsContainedInPolygon(t *testing.T) {
+	// L-shaped board with a square cutout in its lower arm
+	board := geom.NewPolygonFlat(geom.XY, []float64{
+		0, 0, 10, 0, 10, 4, 4, 4, 4, 10, 0, 10, 0, 0,
+		6, 1, 8, 1, 8, 3, 6, 3, 6, 1,
+	}, []int{14, 24})
+
+	cases := []struct {
+		name     string
+		p        *geom.Polygon
+		expected bool
+	}{
+		{"inside", geom.NewPolygonFlat(geom.XY, []float64{1, 1, 3, 1, 3, 3, 1, 3, 1, 1}, []int{10}), true},
+		{"across the notch", geom.NewPolygonFlat(geom.XY, []float64{3, 3, 5, 3, 5, 5, 3, 5, 3, 3}, []int{10}), false},
+		{"vertices around the notch", geom.NewPolygonFlat(geom.XY, []float64{1, 3, 9, 3, 3, 9, 1, 9, 1, 3}, []int{10}), false},
+		{"in the cutout", geom.NewPolygonFlat(geom.XY, []float64{6.5, 1.5, 7.5, 1.5, 7.5, 2.5, 6.5, 2.5, 6.5, 1.5}, []int{10}), false},
+		{"around the cutout", geom.NewPolygonFlat(geom.XY, []float64{5, 0.5, 9, 0.5, 9, 3.5, 5, 3.5, 5, 0.5}, []int{10}), false},
+	}
+
+	for _, c := range cases {
+		if res := geo.IsContainedInPolygon(c.p, board); res != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, res)
+		}
+	}
+}
+
+func TestPolylineToPolygon(t *testing.T) {
+	path := []geom.Coord{{0, 0}, {10, 0}, {10, 0}, {10, 10}}
+	p := geo.PolylineToPolygon(path, 2)
+
+	// A mitered L is as large as its center line times its width
+	if area := p.Area(); math.Abs(area-40) > 1e-9 {
+		t.Errorf("Expected area = 40, got %v", area)
+	}
+
+	if length := geo.PolylineLength(path); length != 20 {
+		t.Errorf("Expected length = 20, got %v", length)
+	}
+
+	// The outer corner of the bend is covered, the inner one is not
+	if !geo.IsPointInPolygon(geom.Coord{10.9, -0.9}, p) || geo.IsPointInPolygon(geom.Coord{8.9, 1.1}, p) {
+		t.Errorf("Unexpected bend shape %v", p.FlatCoords())
+	}
+}
+
+func square(x1, y1, x2, y2 float64) *geom.Polygon {
+	return geom.NewPolygonFlat(geom.XY, []float64{x1, y1, x2, y1, x2, y2, x1, y2, x1, y1}, []int{10})
+}
+
+func TestClip(t *testing.T) {
+	area := func(polys []*geom.Polygon) float64 {
+		res := 0.0
+		for _, p := range polys {
+			res += p.Area()
+		}
+		return res
+	}
+
+	diamond := geom.NewPolygonFlat(geom.XY, []float64{5, 2, 8, 5, 5, 8, 2, 5, 5, 2}, []int{10})
+	holed := geom.NewPolygonFlat(geom.XY, []float64{0, 0, 10, 0, 10, 10, 0, 10, 0, 0, 1, 1, 1, 2, 2, 2, 2, 1, 1, 1}, []int{10, 20})
+
+	cases := []struct {
+		name            string
+		inside, outside []*geom.Polygon
+		area            float64
+	}{
+		{"hole", []*geom.Polygon{square(0, 0, 10, 10)}, []*geom.Polygon{square(4, 4, 6, 6)}, 96},
+		{"intersection", []*geom.Polygon{square(0, 0, 10, 10), square(5, 5, 15, 15)}, nil, 25},
+		{"overlapping holes", []*geom.Polygon{square(0, 0, 10, 10)}, []*geom.Polygon{square(2, 2, 6, 6), square(4, 4, 8, 8)}, 100 - 28},
+		{"crossing", []*geom.Polygon{square(0, 0, 10, 10)}, []*geom.Polygon{square(4, 1, 6, 9), diamond}, 100 - (16 + 18 - 10)},
+		{"ring hole", []*geom.Polygon{holed}, []*geom.Polygon{square(8, 8, 12, 12)}, 100 - 1 - 4},
+	}
+
+	for _, tc := range cases {
+		if a := area(geo.Clip(tc.inside, tc.outside)); math.Abs(a-tc.area) > 1e-6 {
+			t.Errorf("%s: expected area %v, got %v", tc.name, tc.area, a)
+		}
+	}
+
+	if a := geo.OffsetConvex(square(0, 0, 10, 10), 1).Area(); math.Abs(a-144) > 1e-9 {
+		t.Errorf("Expected the offset square area to be 144, got %v", a)
+	}
+}
+
+func TestSpatialGrid(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	boxes := make([]*geom.Bounds, 300)
+
+	for i := range boxes {
+		x, y := r.Float64()*500, r.Float64()*500
+		w, h := 1+r.Float64()*10, 1+r.Float64()*10
+
+		// Some long boxes, like edges crossing the board
+		if i%10 == 0 {
+			w *= 30
+		}
+
+		boxes[i] = geom.NewBounds(geom.XY).Set(x, y, x+w, y+h)
+	}
+
+	margin := 3.0
+	grid := geo.NewSpatialGrid(boxes, margin)
+	found := make(map[[2]int]int)
+
+	grid.Pairs(func(i, j int) {
+		found[[2]int{i, j}]++
+	})
+
+	for i := range boxes {
+		for j := i + 1; j < len(boxes); j++ {
+			dx := math.Max(boxes[i].Min(0)-boxes[j].Max(0), boxes[j].Min(0)-boxes[i].Max(0))
+			dy := math.Max(boxes[i].Min(1)-boxes[j].Max(1), boxes[j].Min(1)-boxes[i].Max(1))
+			close := dx < margin && dy < margin
+
+			if close && found[[2]int{i, j}] != 1 {
+				t.Fatalf("Pair %d-%d is closer than the margin but was found %d times", i, j, found[[2]int{i, j}])
+			}
+		}
+	}
+
+	for pair, n := range found {
+		if n != 1 || pair[0] >= pair[1] {
+			t.Fatalf("Pair %v found %d times", pair, n)
+		}
+	}
+
+	// Far apart boxes are mostly filtered out
+	if len(found) > len(boxes)*len(boxes)/20 {
+		t.Errorf("Too many candidate pairs: %d", len(found))
+	}
+}
+
+func TestWirelength(t *testing.T) {
+	// Unit square: half perimeter 2, spanning tree 3 sides, Steiner tree 1+sqrt(3)
+	square := []geom.Coord{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+	if l := geo.HalfPerimeter(square); l != 2 {
+		t.Errorf("Expected a half perimeter of 2, got %v", l)
+	}
+
+	if l := geo.MinimumSpanningTreeLength(square); math.Abs(l-3) > 1e-9 {
+		t.Errorf("Expected a spanning tree of length 3, got %v", l)
+	}
+
+	if l := geo.SteinerTreeLength(square); l >= 3 || l < 1+math.Sqrt(3)-1e-9 {
+		t.Errorf("Expected a Steiner tree between %v and 3, got %v", 1+math.Sqrt(3), l)
+	}
+
+	// Equilateral triangle of side 1, joined through its center
+	triangle := []geom.Coord{{0, 0}, {1, 0}, {0.5, math.Sqrt(3) / 2}}
+
+	if l := geo.SteinerTreeLength(triangle); math.Abs(l-math.Sqrt(3)) > 1e-9 {
+		t.Errorf("Expected a Steiner tree of length %v, got %v", math.Sqrt(3), l)
+	}
+
+	// Nothing to gain on collinear points
+	line := []geom.Coord{{0, 0}, {3, 0}, {1, 0}, {2, 0}}
+
+	if mst, steiner := geo.MinimumSpanningTreeLength(line), geo.SteinerTreeLength(line); mst != 3 || steiner != 3 {
+		t.Errorf("Expected trees of length 3, got %v and %v", mst, steiner)
+	}
+
+	if edges := geo.MinimumSpanningTree(line); len(edges) != 3 {
+		t.Errorf("Expected 3 edges, got %v", edges)
+	}
+
+	if l := geo.SteinerTreeLength(line[:1]); l != 0 {
+		t.Errorf("Expected 0 for a single point, got %v", l)
+	}
+}
@@ -0,0 +1,125 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/twpayne/go-geom"
+)
+
+// Maximum number of cells of a SpatialGrid along each axis
+const maxGridCells = 256
+
+// SpatialGrid is a uniform grid indexing bounding boxes, to find the boxes close to a given one
+// without comparing it with all of them. Boxes are stored in every cell they overlap once grown
+// by half the margin, so that two boxes closer than the margin always share a cell.
+type SpatialGrid struct {
+	boxes    []*geom.Bounds
+	margin   float64
+	minX     float64
+	minY     float64
+	cellSize float64
+	nx, ny   int
+	cells    [][]int
+	// seen marks the boxes already returned by the current query
+	seen  []int
+	query int
+}
+
+// NewSpatialGrid indexes the boxes, which are identified by their index, for queries of boxes closer than margin.
+// The cell size follows the average size of the boxes.
+func NewSpatialGrid(boxes []*geom.Bounds, margin float64) *SpatialGrid {
+	g := &SpatialGrid{boxes: boxes, margin: margin, seen: make([]int, len(boxes))}
+
+	if len(boxes) == 0 {
+		return g
+	}
+
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	g.minX, g.minY = math.Inf(1), math.Inf(1)
+	size := 0.0
+
+	for _, b := range boxes {
+		g.minX, g.minY = math.Min(g.minX, b.Min(0)), math.Min(g.minY, b.Min(1))
+		maxX, maxY = math.Max(maxX, b.Max(0)), math.Max(maxY, b.Max(1))
+		size += math.Max(b.Max(0)-b.Min(0), b.Max(1)-b.Min(1))
+	}
+
+	g.minX -= margin / 2
+	g.minY -= margin / 2
+	width, height := maxX-g.minX+margin/2, maxY-g.minY+margin/2
+
+	g.cellSize = math.Max(size/float64(len(boxes))+margin, math.Max(width, height)/maxGridCells)
+	if g.cellSize <= 0 {
+		g.cellSize = 1
+	}
+
+	g.nx, g.ny = int(width/g.cellSize)+1, int(height/g.cellSize)+1
+	g.cells = make([][]int, g.nx*g.ny)
+
+	for i, b := range boxes {
+		i1, j1, i2, j2 := g.cellRange(b)
+
+		for j := j1; j <= j2; j++ {
+			for k := i1; k <= i2; k++ {
+				g.cells[j*g.nx+k] = append(g.cells[j*g.nx+k], i)
+			}
+		}
+	}
+
+	return g
+}
+
+// cellRange returns the first and last columns and rows of the cells overlapped by the box grown
+// by half the margin, clipped to the grid.
+func (g *SpatialGrid) cellRange(b *geom.Bounds) (int, int, int, int) {
+	half := g.margin / 2
+	clamp := func(v float64, n int) int {
+		return int(math.Max(0, math.Min(float64(n-1), math.Floor(v))))
+	}
+
+	return clamp((b.Min(0)-half-g.minX)/g.cellSize, g.nx),
+		clamp((b.Min(1)-half-g.minY)/g.cellSize, g.ny),
+		clamp((b.Max(0)+half-g.minX)/g.cellSize, g.nx),
+		clamp((b.Max(1)+half-g.minY)/g.cellSize, g.ny)
+}
+
+// Query calls f once for the index of every box that shares a cell with b once both are grown by
+// half the margin. They include all the boxes closer than the margin to b.
+func (g *SpatialGrid) Query(b *geom.Bounds, f func(i int)) {
+	if len(g.boxes) == 0 {
+		return
+	}
+
+	g.query++
+	query := g.query
+	i1, j1, i2, j2 := g.cellRange(b)
+
+	for j := j1; j <= j2; j++ {
+		for k := i1; k <= i2; k++ {
+			for _, id := range g.cells[j*g.nx+k] {
+				if g.seen[id] != query {
+					g.seen[id] = query
+					f(id)
+				}
+			}
+		}
+	}
+}
+
+// Pairs calls f once for every pair of indexed boxes i < j that share a cell.
+func (g *SpatialGrid) Pairs(f func(i, j int)) {
+	candidates := make([]int, 0)
+
+	for i, b := range g.boxes {
+		candidates = candidates[:0]
+		g.Query(b, func(j int) {
+			if j > i {
+				candidates = append(candidates, j)
+			}
+		})
+
+		for _, j := range candidates {
+			f(i, j)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package geo
+
+import (
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/xy"
+)
+
+func orientation(a, b, c geom.Coord) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// SegmentsCross tells whether segments a1-a2 and b1-b2 cross at a point interior to both of them.
+// Touching endpoints and collinear overlaps are not considered crossings.
+func SegmentsCross(a1, a2, b1, b2 geom.Coord) bool {
+	o1 := orientation(a1, a2, b1)
+	o2 := orientation(a1, a2, b2)
+	o3 := orientation(b1, b2, a1)
+	o4 := orientation(b1, b2, a2)
+
+	return o1*o2 < 0 && o3*o4 < 0
+}
+
+// IsPointInPolygon tells whether pt is inside the outer ring of p and outside all of its holes.
+func IsPointInPolygon(pt geom.Coord, p *geom.Polygon) bool {
+	if !xy.IsPointInRing(geom.XY, pt, p.LinearRing(0).FlatCoords()) {
+		return false
+	}
+
+	for i := 1; i < p.NumLinearRings(); i++ {
+		if xy.IsPointInRing(geom.XY, pt, p.LinearRing(i).FlatCoords()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func ringsCross(r1, r2 []geom.Coord) bool {
+	for i := 1; i < len(r1); i++ {
+		for j := 1; j < len(r2); j++ {
+			if SegmentsCross(r1[i-1], r1[i], r2[j-1], r2[j]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsContainedInPolygon tells whether p lies entirely inside container, which may be concave
+// and have holes. Unlike IsContained it also rejects polygons whose vertices are all inside
+// but whose sides cross the container boundary or enclose one of its holes.
+func IsContainedInPolygon(p *geom.Polygon, container *geom.Polygon) bool {
+	ring := p.Coords()[0]
+
+	for _, pt := range ring {
+		if !IsPointInPolygon(pt, container) {
+			return false
+		}
+	}
+
+	for i, cRing := range container.Coords() {
+		if ringsCross(ring, cRing) {
+			return false
+		}
+
+		if i > 0 && xy.IsPointInRing(geom.XY, cRing[0], p.LinearRing(0).FlatCoords()) {
+			return false
+		}
+	}
+
+	return true
+}
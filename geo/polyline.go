@@ -0,0 +1,118 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/xy"
+)
+
+// dedupPolyline drops the points equal to the previous one.
+func dedupPolyline(points []geom.Coord) []geom.Coord {
+	res := make([]geom.Coord, 0, len(points))
+
+	for _, p := range points {
+		if len(res) == 0 || res[len(res)-1][0] != p[0] || res[len(res)-1][1] != p[1] {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// leftNormal returns the unit normal on the left of the segment a-b.
+func leftNormal(a, b geom.Coord) (float64, float64) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	l := math.Hypot(dx, dy)
+
+	return -dy / l, dx / l
+}
+
+// PolylineToPolygon returns the outline of the polyline drawn with the given width, with mitered
+// joints and flat ends. Polylines of a single point become a square as large as the width.
+func PolylineToPolygon(points []geom.Coord, width float64) *geom.Polygon {
+	points = dedupPolyline(points)
+	half := width / 2
+
+	if len(points) == 1 {
+		x, y := points[0][0], points[0][1]
+		return geom.NewPolygonFlat(geom.XY, []float64{x - half, y - half, x + half, y - half, x + half, y + half, x - half, y + half, x - half, y - half}, []int{10})
+	}
+
+	left := make([]float64, 0, 2*len(points))
+	right := make([]float64, 0, 2*len(points))
+
+	for i, p := range points {
+		var nx, ny float64
+
+		switch {
+		case i == 0:
+			nx, ny = leftNormal(p, points[1])
+			nx, ny = nx*half, ny*half
+		case i == len(points)-1:
+			nx, ny = leftNormal(points[i-1], p)
+			nx, ny = nx*half, ny*half
+		default:
+			// The miter of the two segments normals, as long as needed to keep the width
+			n1x, n1y := leftNormal(points[i-1], p)
+			n2x, n2y := leftNormal(p, points[i+1])
+			sx, sy := n1x+n2x, n1y+n2y
+			sq := sx*sx + sy*sy
+
+			if sq < 1e-12 {
+				nx, ny = n1x*half, n1y*half
+			} else {
+				nx, ny = sx*width/sq, sy*width/sq
+			}
+		}
+
+		left = append(left, p[0]+nx, p[1]+ny)
+		right = append(right, p[0]-nx, p[1]-ny)
+	}
+
+	flatCoords := make([]float64, 0, len(left)+len(right)+2)
+	flatCoords = append(flatCoords, right...)
+
+	for i := len(left) - 2; i >= 0; i -= 2 {
+		flatCoords = append(flatCoords, left[i], left[i+1])
+	}
+
+	flatCoords = append(flatCoords, right[0], right[1])
+
+	return geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+}
+
+// PolylineLength returns the sum of the lengths of the segments of the polyline.
+func PolylineLength(points []geom.Coord) float64 {
+	res := 0.0
+
+	for i := 1; i < len(points); i++ {
+		res += math.Hypot(points[i][0]-points[i-1][0], points[i][1]-points[i-1][1])
+	}
+
+	return res
+}
+
+// PolylineDistance returns the distance between two polylines, the smallest one between their segments.
+func PolylineDistance(a, b []geom.Coord) float64 {
+	res := math.Inf(1)
+
+	for i := 1; i < len(a); i++ {
+		for j := 1; j < len(b); j++ {
+			res = math.Min(res, xy.DistanceFromLineToLine(a[i-1], a[i], b[j-1], b[j]))
+		}
+	}
+
+	return res
+}
+
+// PointToPolylineDist returns the distance from pt to the closest segment of the polyline.
+func PointToPolylineDist(pt geom.Coord, points []geom.Coord) float64 {
+	res := math.Inf(1)
+
+	for i := 1; i < len(points); i++ {
+		res = math.Min(res, xy.DistanceFromPointToLine(pt, points[i-1], points[i]))
+	}
+
+	return res
+}
@@ -1,6 +1,10 @@
 package geo
 
-import "math"
+import (
+	"math"
+
+	"github.com/twpayne/go-geom"
+)
 
 func RotatePoint(x, y, centerX, centerY, angle float64) (float64, float64) {
 	// Translate the point relative to the center
@@ -20,3 +24,17 @@ func RotatePoint(x, y, centerX, centerY, angle float64) (float64, float64) {
 
 	return rotatedX, rotatedY
 }
+
+// RegularPolygon approximates the circle of the given center and radius with a polygon of n sides.
+func RegularPolygon(centerX, centerY, radius float64, n int) *geom.Polygon {
+	flatCoords := make([]float64, 0, 2*n+2)
+
+	for i := 0; i < n; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		flatCoords = append(flatCoords, centerX+radius*math.Cos(angle), centerY+radius*math.Sin(angle))
+	}
+
+	flatCoords = append(flatCoords, flatCoords[0], flatCoords[1])
+
+	return geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+}
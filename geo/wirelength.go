@@ -0,0 +1,157 @@
+package geo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/twpayne/go-geom"
+)
+
+func pointDist(a, b geom.Coord) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// HalfPerimeter returns the half perimeter of the bounding box of the points.
+func HalfPerimeter(points []geom.Coord) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+
+	minX, minY, maxX, maxY := points[0][0], points[0][1], points[0][0], points[0][1]
+
+	for _, p := range points[1:] {
+		minX, minY = math.Min(minX, p[0]), math.Min(minY, p[1])
+		maxX, maxY = math.Max(maxX, p[0]), math.Max(maxY, p[1])
+	}
+
+	return maxX - minX + maxY - minY
+}
+
+// MinimumSpanningTree returns the edges, as pairs of indices of points, of the euclidean minimum
+// spanning tree of the points.
+func MinimumSpanningTree(points []geom.Coord) [][2]int {
+	res := make([][2]int, 0, len(points))
+	if len(points) < 2 {
+		return res
+	}
+
+	// Prim on the complete graph, distance and closest tree point of every point out of the tree
+	dist := make([]float64, len(points))
+	closest := make([]int, len(points))
+	inTree := make([]bool, len(points))
+
+	for i := range points {
+		dist[i] = pointDist(points[0], points[i])
+	}
+	inTree[0] = true
+
+	for len(res) < len(points)-1 {
+		next := -1
+		for i := range points {
+			if !inTree[i] && (next < 0 || dist[i] < dist[next]) {
+				next = i
+			}
+		}
+
+		inTree[next] = true
+		res = append(res, [2]int{closest[next], next})
+
+		for i := range points {
+			if d := pointDist(points[next], points[i]); !inTree[i] && d < dist[i] {
+				dist[i], closest[i] = d, next
+			}
+		}
+	}
+
+	return res
+}
+
+// MinimumSpanningTreeLength returns the length of the euclidean minimum spanning tree of the points.
+func MinimumSpanningTreeLength(points []geom.Coord) float64 {
+	res := 0.0
+
+	for _, e := range MinimumSpanningTree(points) {
+		res += pointDist(points[e[0]], points[e[1]])
+	}
+
+	return res
+}
+
+// fermatLength returns the length of the shortest tree joining the three points, through their
+// Fermat point unless the triangle has an angle of 120 degrees or more.
+func fermatLength(a, b, c geom.Coord) float64 {
+	ab, bc, ca := pointDist(a, b), pointDist(b, c), pointDist(c, a)
+
+	// The tree is made of the two sides adjacent to the obtuse angle
+	cosA := (ab*ab + ca*ca - bc*bc) / (2 * ab * ca)
+	cosB := (ab*ab + bc*bc - ca*ca) / (2 * ab * bc)
+	cosC := (bc*bc + ca*ca - ab*ab) / (2 * bc * ca)
+
+	switch {
+	case ab == 0 || ca == 0 || bc == 0:
+		return ab + bc + ca - math.Max(ab, math.Max(bc, ca))
+	case cosA <= -0.5:
+		return ab + ca
+	case cosB <= -0.5:
+		return ab + bc
+	case cosC <= -0.5:
+		return bc + ca
+	}
+
+	area := math.Abs((b[0]-a[0])*(c[1]-a[1])-(c[0]-a[0])*(b[1]-a[1])) / 2
+
+	return math.Sqrt((ab*ab+bc*bc+ca*ca)/2 + 2*math.Sqrt(3)*area)
+}
+
+// SteinerTreeLength estimates the length of the euclidean Steiner minimum tree of the points. It
+// improves their minimum spanning tree by joining pairs of edges meeting at less than 120 degrees
+// through a Fermat point, using every edge at most once, the largest savings first.
+func SteinerTreeLength(points []geom.Coord) float64 {
+	edges := MinimumSpanningTree(points)
+	res := 0.0
+	adjacent := make([][]int, len(points))
+
+	for i, e := range edges {
+		res += pointDist(points[e[0]], points[e[1]])
+		adjacent[e[0]] = append(adjacent[e[0]], i)
+		adjacent[e[1]] = append(adjacent[e[1]], i)
+	}
+
+	type saving struct {
+		e1, e2 int
+		length float64
+	}
+
+	other := func(e, p int) int {
+		if edges[e][0] == p {
+			return edges[e][1]
+		}
+
+		return edges[e][0]
+	}
+
+	savings := make([]saving, 0)
+
+	for p, adj := range adjacent {
+		for i, e1 := range adj {
+			for _, e2 := range adj[i+1:] {
+				a, b := points[other(e1, p)], points[other(e2, p)]
+				if s := pointDist(points[p], a) + pointDist(points[p], b) - fermatLength(points[p], a, b); s > 0 {
+					savings = append(savings, saving{e1, e2, s})
+				}
+			}
+		}
+	}
+
+	sort.Slice(savings, func(i, j int) bool { return savings[i].length > savings[j].length })
+	used := make([]bool, len(edges))
+
+	for _, s := range savings {
+		if !used[s.e1] && !used[s.e2] {
+			used[s.e1], used[s.e2] = true, true
+			res -= s.length
+		}
+	}
+
+	return res
+}
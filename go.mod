@@ -3,13 +3,10 @@ module genetic_pcb
 go 1.20
 
 require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/llgcode/draw2d v0.0.0-20210904075650-80aa0a2a901d
 	github.com/mroth/weightedrand/v2 v2.0.1
 	github.com/twpayne/go-geom v1.5.2
+	golang.org/x/image v0.7.0
 	gonum.org/v1/gonum v0.12.0
 )
-
-require (
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/image v0.7.0 // indirect
-)
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"genetic_pcb/drc"
 	"genetic_pcb/genetic"
 	"genetic_pcb/pcb"
+	"genetic_pcb/pipeline"
+	"genetic_pcb/problem"
+	"genetic_pcb/router"
 	"image/color"
 	"log"
 	"math/rand"
@@ -14,6 +19,18 @@ import (
 )
 
 func main() {
+	problemPath := flag.String("problem", "", "JSON problem definition, a random problem is generated if empty")
+	solutionPath := flag.String("solution", "best.json", "where the best solution is written when -problem is given")
+	drcPath := flag.String("drc", "", "where the design rule check report of the best solution is written, as JSON if it ends in .json")
+	route := flag.Bool("route", false, "reroute the violating edges of the best solution on a grid when the GA ends")
+	routeOnly := flag.Bool("route-only", false, "skip the GA and reroute the violating edges of the problem placement")
+	breakdown := flag.Bool("breakdown", false, "print the cost of every evaluation term of the best individual after each generation")
+	twoPhase := flag.Bool("two-phase", false, "optimize the placement without routing first, then the routing with the placement frozen")
+	placementGenerations := flag.Int("placement-generations", 1000, "generations of the placement phase, unless the problem gives them")
+	routingGenerations := flag.Int("routing-generations", 2000, "generations of the routing phase, unless the problem gives them")
+	verifyIncremental := flag.Bool("verify-incremental", false, "check the incremental evaluation of every child against a full one, for debugging")
+	flag.Parse()
+
 	fmt.Println("Hi!")
 
 	go func() {
@@ -70,7 +87,6 @@ func main() {
 	// p1 := pcb.GeneratePcbFull(componentTemplates, 20, 6, maxX, maxY, randomGenerator)
 	// p1 := pcb.GeneratePcbFull(componentTemplates, 7, 3, maxX, maxY, randomGenerator)
 	p1 := pcb.GeneratePcbFull(componentTemplates, 25, 10, maxX, maxY, randomGenerator)
-	p2 := pcb.ScrumblePcb(p1, maxX, maxY)
 	pgo := pcb.NewPcbGeneticOperators(
 		1,
 		0.2,
@@ -87,6 +103,11 @@ func main() {
 			RotateComponentMutationWeight:         10,
 			RerouteEdgeMutationWeight:             10,
 			ChangePlaneMutationWeight:             10,
+			BreakEdgeMutationWeight:               5,
+			JunctionMutationWeight:                5,
+			MoveWaypointMutationWeight:            10,
+			RemoveWaypointMutationWeight:          5,
+			MergeWaypointsMutationWeight:          2,
 		},
 		pcb.EvaluationParams{
 			SamePlaneIntersectionCost:      1.0,
@@ -97,6 +118,89 @@ func main() {
 			MinDist:                        2,
 		},
 	)
+
+	var prob *problem.Problem
+
+	if *problemPath != "" {
+		var err error
+
+		prob, err = problem.Load(*problemPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		p1, err = prob.BuildPcb(randomGenerator)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pgo, err = prob.BuildOperators()
+		if err != nil {
+			log.Fatal(err)
+		}
+		maxX, maxY = pgo.Board().Bounds().Max(0), pgo.Board().Bounds().Max(1)
+		nodeSz, edgeSz = prob.Rules.NodeSize, prob.Rules.EdgeSize
+	}
+
+	if *routeOnly {
+		routeAndSave(pgo, prob, p1, *solutionPath, *drcPath, netColors)
+		return
+	}
+
+	pgo.SetVerifyIncremental(*verifyIncremental)
+
+	if *twoPhase {
+		placement := pipeline.Phase{Operators: pgo, Generations: *placementGenerations}
+		routing := pipeline.Phase{Operators: pgo, Generations: *routingGenerations}
+
+		if prob != nil && prob.Phases != nil {
+			var err error
+
+			if placement, err = phaseOf(prob, prob.Phases.Placement, placement, *verifyIncremental); err != nil {
+				log.Fatal(err)
+			}
+
+			if routing, err = phaseOf(prob, prob.Phases.Routing, routing, *verifyIncremental); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		pl := pipeline.New(placement, routing, pipeline.Params{
+			PopulationSize: N,
+			OnGeneration: func(phase string, stats genetic.Statistics[*pcb.Pcb], details fmt.Stringer) {
+				fmt.Printf("%s %d: %v %v\n", phase, stats.Generation, stats.Best.Fitness, stats.WorstFitness)
+
+				if details != nil {
+					fmt.Print(details)
+				}
+			},
+			Explain: *breakdown,
+		})
+		report := pl.Run(p1)
+		best := report.Routing.Best
+
+		fmt.Print(report)
+		pcb.DrawPcbToImage(report.Placement.Best, pgo.Board(), "placed.png", int(maxX), int(maxY), 1, 1, netColors)
+		pcb.DrawPcbToImage(best, pgo.Board(), "best.png", int(maxX), int(maxY), 1, 1, netColors)
+
+		if prob != nil {
+			saveSolution(prob, routing.Operators, best, *solutionPath)
+		}
+
+		if *drcPath != "" {
+			if err := drc.Check(routing.Operators, best).Save(*drcPath); err != nil {
+				log.Println(err)
+			}
+		}
+
+		if *route {
+			routeAndSave(routing.Operators, prob, best, *solutionPath, *drcPath, netColors)
+		}
+
+		return
+	}
+
+	p2 := pcb.ScrumblePcbOnBoard(p1, pgo.Board())
 	ctx := genetic.NewGeneticContext()
 	c := pgo.CrossOver(p1, p2, ctx)
 	fmt.Printf("%+v\n", p1.Genome)
@@ -109,14 +213,14 @@ func main() {
 
 	c.Genome.Edges[0].Plane = 1
 
-	pcb.DrawPcbToImage(p1, "p1.png", int(maxX), int(maxY), 1, 1, netColors)
-	pcb.DrawPcbToImage(p2, "p2.png", int(maxX), int(maxY), 1, 1, netColors)
-	pcb.DrawPcbToImage(c, "c.png", int(maxX), int(maxY), 1, 1, netColors)
+	pcb.DrawPcbToImage(p1, pgo.Board(), "p1.png", int(maxX), int(maxY), 1, 1, netColors)
+	pcb.DrawPcbToImage(p2, pgo.Board(), "p2.png", int(maxX), int(maxY), 1, 1, netColors)
+	pcb.DrawPcbToImage(c, pgo.Board(), "c.png", int(maxX), int(maxY), 1, 1, netColors)
 
 	// pgo.MoveEdgeMutation(c, ctx)
 	// c.ComputeGeometry(nodeSz, edgeSz)
 
-	// pcb.DrawPcbToImage(c, "c2.png", int(maxX), int(maxY), 1, 1, netColors)
+	// pcb.DrawPcbToImage(c, pgo.Board(), "c2.png", int(maxX), int(maxY), 1, 1, netColors)
 
 	// // // p := pcb.GeneratePcb(25, 40, maxX, maxY)
 	// // p := pcb.GeneratePcbWithNets(5, 6, maxX, maxY)
@@ -126,7 +230,7 @@ func main() {
 	initialPop := make([]*pcb.Pcb, N)
 
 	for i := 0; i < N; i++ {
-		initialPop[i] = pcb.ScrumblePcb(p1, maxX, maxY)
+		initialPop[i] = pcb.ScrumblePcbOnBoard(p1, pgo.Board())
 	}
 
 	ga := genetic.NewGeneticAlgorithm[*pcb.Pcb](
@@ -138,7 +242,7 @@ func main() {
 		0.01,
 	)
 
-	pcb.DrawPcbToImage(ga.CurrentPop[0].Individual, "first.png", int(maxX), int(maxY), 1, 1, netColors)
+	pcb.DrawPcbToImage(ga.CurrentPop[0].Individual, pgo.Board(), "first.png", int(maxX), int(maxY), 1, 1, netColors)
 
 	prevValue := 0.0
 
@@ -154,9 +258,23 @@ func main() {
 		currValue := ga.CurrentPop[0].Fitness
 
 		if currValue != prevValue {
-			pcb.DrawPcbToImage(ga.CurrentPop[0].Individual, "best_nw.png", int(maxX), int(maxY), 1, 1, netColors)
+			pcb.DrawPcbToImage(ga.CurrentPop[0].Individual, pgo.Board(), "best_nw.png", int(maxX), int(maxY), 1, 1, netColors)
 			os.Rename("best_nw.png", "best.png")
 
+			if err := pcb.DrawPcbToSvg(ga.CurrentPop[0].Individual, pgo.Board(), "best.svg", netColors); err != nil {
+				log.Println(err)
+			}
+
+			if prob != nil {
+				saveSolution(prob, pgo, ga.CurrentPop[0].Individual, *solutionPath)
+			}
+
+			if *drcPath != "" {
+				if err := drc.Check(pgo, ga.CurrentPop[0].Individual).Save(*drcPath); err != nil {
+					log.Println(err)
+				}
+			}
+
 			prevValue = currValue
 		}
 
@@ -165,9 +283,76 @@ func main() {
 		fmt.Printf("%v %v\n", ga.CurrentPop[0].Fitness, ga.CurrentPop[len(ga.CurrentPop)-1].Fitness)
 		fmt.Println(ga.CurrentPop[0].Individual.Genome.Edges)
 
+		if *breakdown {
+			fmt.Print(ga.Explain())
+		}
+
 		// if ga.CurrentPop[0].Fitness > -1 {
 		// 	break
 		// }
 
 	}
+
+	if *route {
+		routeAndSave(pgo, prob, ga.CurrentPop[0].Individual, *solutionPath, *drcPath, netColors)
+	}
+}
+
+// routeAndSave reroutes the violating edges of p on a grid, then draws and saves the result as the best solution.
+func routeAndSave(pgo *pcb.PcbGeneticOperators, prob *problem.Problem, p *pcb.Pcb, solutionPath, drcPath string, netColors []color.Color) {
+	res := router.New(pgo, router.Params{}).Route(p)
+	fmt.Printf("Rerouted %d edges, %d failed\n", res.Rerouted, res.Failed)
+
+	bounds := pgo.Board().Bounds()
+	pcb.DrawPcbToImage(p, pgo.Board(), "routed.png", int(bounds.Max(0)), int(bounds.Max(1)), 1, 1, netColors)
+
+	if err := pcb.DrawPcbToSvg(p, pgo.Board(), "routed.svg", netColors); err != nil {
+		log.Println(err)
+	}
+
+	if prob != nil {
+		saveSolution(prob, pgo, p, solutionPath)
+	}
+
+	if drcPath != "" {
+		if err := drc.Check(pgo, p).Save(drcPath); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// saveSolution writes the problem with the placement and routes of best, on its tight outline if the board is automatically sized.
+func saveSolution(prob *problem.Problem, pgo *pcb.PcbGeneticOperators, best *pcb.Pcb, path string) {
+	solved, err := prob.WithSolution(best)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if outline := pgo.TightOutline(best); prob.Board.AutoSize && outline != nil {
+		b := outline.Bounds()
+		fmt.Printf("Tight board outline: %.3f x %.3f at (%.3f, %.3f), area %.3f\n", b.Max(0)-b.Min(0), b.Max(1)-b.Min(1), b.Min(0), b.Min(1), outline.Area())
+		solved = solved.WithTightBoard(outline)
+	}
+
+	if err := solved.Save(path); err != nil {
+		log.Println(err)
+	}
+}
+
+// phaseOf returns the phase of the pipeline configured by the problem, keeping the generations of
+// def if the problem doesn't give them.
+func phaseOf(prob *problem.Problem, phase problem.Phase, def pipeline.Phase, verifyIncremental bool) (pipeline.Phase, error) {
+	pgo, err := prob.BuildPhaseOperators(phase)
+	if err != nil {
+		return def, err
+	}
+	pgo.SetVerifyIncremental(verifyIncremental)
+
+	res := pipeline.Phase{Operators: pgo, Generations: def.Generations}
+	if phase.Generations > 0 {
+		res.Generations = phase.Generations
+	}
+
+	return res, nil
 }
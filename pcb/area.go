@@ -0,0 +1,76 @@
+package pcb
+
+import (
+	"genetic_pcb/geo"
+
+	"github.com/twpayne/go-geom"
+	"github.com/twpayne/go-geom/xy"
+)
+
+// TightOutline returns the smallest rectangle, or the convex hull if EvaluationParams.BoardAreaHull,
+// enclosing the real components, pads, edges and vias of pcb at EvaluationParams.MinDist from them.
+// It is the outline automatically sized boards shrink to, nil if pcb has nothing to enclose.
+func (pgo *PcbGeneticOperators) TightOutline(pcb *Pcb) *geom.Polygon {
+	coords := make([]float64, 0)
+	add := func(polys []*geom.Polygon) {
+		for _, p := range polys {
+			if p.NumLinearRings() > 0 {
+				coords = append(coords, p.LinearRing(0).FlatCoords()...)
+			}
+		}
+	}
+
+	add(pcb.Geometry.Components[:pcb.Genome.realComponents()])
+	add(pcb.Geometry.Nodes)
+	add(pcb.Geometry.Edges)
+	add(pcb.Geometry.Vias)
+
+	if len(coords) == 0 {
+		return nil
+	}
+
+	margin := pgo.evaluationParams.MinDist
+
+	if pgo.evaluationParams.BoardAreaHull {
+		if hull, ok := xy.ConvexHullFlat(geom.XY, coords).(*geom.Polygon); ok {
+			return geo.OffsetConvex(hull, margin)
+		}
+	}
+
+	b := geom.NewBounds(geom.XY).Extend(geom.NewMultiPointFlat(geom.XY, coords))
+	x1, y1, x2, y2 := b.Min(0)-margin, b.Min(1)-margin, b.Max(0)+margin, b.Max(1)+margin
+
+	return geom.NewPolygonFlat(geom.XY, []float64{x1, y1, x2, y1, x2, y2, x1, y2, x1, y1}, []int{10})
+}
+
+// areaTerm charges the area of the tight outline of a pcb relative to the area of the board, which
+// is only an upper limit for automatically sized boards.
+type areaTerm struct {
+	pgo *PcbGeneticOperators
+}
+
+func (t *areaTerm) Name() string {
+	return "board area"
+}
+
+func (t *areaTerm) Weight() float64 {
+	return t.pgo.evaluationParams.BoardAreaCost
+}
+
+func (t *areaTerm) area(pcb *Pcb) float64 {
+	if outline := t.pgo.TightOutline(pcb); outline != nil {
+		return outline.Area()
+	}
+
+	return 0
+}
+
+func (t *areaTerm) Evaluate(pcb *Pcb) float64 {
+	return t.area(pcb) / t.pgo.board.Outline.Area()
+}
+
+func (t *areaTerm) Breakdown(pcb *Pcb) TermBreakdown {
+	area := t.area(pcb)
+
+	return TermBreakdown{Name: t.Name(), Raw: area, Cost: t.Weight() * area / t.pgo.board.Outline.Area()}
+}
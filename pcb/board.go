@@ -0,0 +1,162 @@
+package pcb
+
+import (
+	"genetic_pcb/geo"
+	"image/color"
+	"math/rand"
+
+	"github.com/twpayne/go-geom"
+)
+
+// Number of positions sampled in the board bounds before giving up on finding one where the
+// component is entirely inside the outline.
+const randomPositionTries = 50
+
+type KeepoutKind int
+
+const (
+	// No component may overlap the area
+	COMPONENT_KEEPOUT KeepoutKind = iota
+	// No edge on the keepout plane may overlap the area
+	ROUTING_KEEPOUT
+)
+
+type Keepout struct {
+	Kind KeepoutKind
+	// Plane is only meaningful for ROUTING_KEEPOUT
+	Plane int
+	Area  *geom.Polygon
+}
+
+// Number of planes of a board with no layer stack
+const DEFAULT_PLANES = 2
+
+var defaultLayerColors = []color.Color{
+	color.RGBA{200, 52, 52, 255},
+	color.RGBA{52, 96, 200, 255},
+	color.RGBA{60, 170, 80, 255},
+	color.RGBA{210, 180, 40, 255},
+	color.RGBA{170, 70, 190, 255},
+	color.RGBA{40, 180, 190, 255},
+}
+
+// Layer is a copper plane of the board, its index in Board.Layers is the Plane of the edges routed on it.
+type Layer struct {
+	Name string
+	// RoutingCost is paid for every edge routed on the layer
+	RoutingCost float64
+	// AllowedNets are the only nets that can be routed on the layer, all of them if nil
+	AllowedNets []int
+	// Color is used by renderers, a default one is picked if nil
+	Color color.Color
+}
+
+type Board struct {
+	// Outline is the board shape, its holes are cutouts where nothing can be placed.
+	Outline  *geom.Polygon
+	Keepouts []Keepout
+	// Layers is the layer stack from top to bottom. Boards without one have DEFAULT_PLANES planes,
+	// with edges outside plane 0 paying EvaluationParams.NonZeroPlaneEdgeCost.
+	Layers []Layer
+	Zones  []Zone
+}
+
+func NewBoard(outline *geom.Polygon) *Board {
+	return &Board{Outline: outline}
+}
+
+func NewRectangularBoard(maxX, maxY float64) *Board {
+	return NewBoard(geom.NewPolygonFlat(geom.XY, []float64{
+		0, 0,
+		maxX, 0,
+		maxX, maxY,
+		0, maxY,
+		0, 0,
+	}, []int{10}))
+}
+
+func (b *Board) Bounds() *geom.Bounds {
+	return b.Outline.Bounds()
+}
+
+func (b *Board) Width() float64 {
+	return b.Bounds().Max(0) - b.Bounds().Min(0)
+}
+
+func (b *Board) Height() float64 {
+	return b.Bounds().Max(1) - b.Bounds().Min(1)
+}
+
+func (b *Board) Contains(p *geom.Polygon) bool {
+	return geo.IsContainedInPolygon(p, b.Outline)
+}
+
+func (b *Board) ContainsPoint(x, y float64) bool {
+	return geo.IsPointInPolygon(geom.Coord{x, y}, b.Outline)
+}
+
+// GetComponentRandomPositionInBoard samples positions in the board bounds until the component fits
+// the outline, returning the last one sampled if none does.
+func GetComponentRandomPositionInBoard(c *Component, b *Board, randomGenerator *rand.Rand) (float64, float64) {
+	bounds := b.Bounds()
+	tmp := *c
+
+	for i := 0; i < randomPositionTries; i++ {
+		x, y := GetComponentRandomPositionInBoundaries(c, b.Width(), b.Height(), randomGenerator)
+		tmp.CX, tmp.CY = x+bounds.Min(0), y+bounds.Min(1)
+
+		if b.Contains(componentToPoly(&tmp)) {
+			break
+		}
+	}
+
+	return tmp.CX, tmp.CY
+}
+
+func (b *Board) NumPlanes() int {
+	if len(b.Layers) == 0 {
+		return DEFAULT_PLANES
+	}
+
+	return len(b.Layers)
+}
+
+func (b *Board) IsNetAllowed(plane, net int) bool {
+	if plane < 0 || plane >= b.NumPlanes() {
+		return false
+	}
+
+	if len(b.Layers) == 0 || b.Layers[plane].AllowedNets == nil {
+		return true
+	}
+
+	for _, n := range b.Layers[plane].AllowedNets {
+		if n == net {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowedPlanes returns the planes where the net can be routed.
+func (b *Board) AllowedPlanes(net int) []int {
+	res := make([]int, 0, b.NumPlanes())
+
+	for plane := 0; plane < b.NumPlanes(); plane++ {
+		if b.IsNetAllowed(plane, net) {
+			res = append(res, plane)
+		}
+	}
+
+	return res
+}
+
+// LayerColor returns the color of the plane, also for nil boards.
+func (b *Board) LayerColor(plane int) color.Color {
+	if b != nil && plane < len(b.Layers) && b.Layers[plane].Color != nil {
+		return b.Layers[plane].Color
+	}
+
+	return defaultLayerColors[plane%len(defaultLayerColors)]
+}
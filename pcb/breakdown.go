@@ -0,0 +1,89 @@
+package pcb
+
+import (
+	"fmt"
+	"genetic_pcb/genetic"
+	"strings"
+)
+
+// TermBreakdown is what a cost term adds to the evaluation of a pcb.
+type TermBreakdown struct {
+	Name string
+	// Raw is the unweighted value of the term, the number of offending objects for the design
+	// rule checks, the length of the edges for the length term, the area of the tight outline
+	// for the board area term and the estimated length of the nets for the wirelength terms
+	Raw float64
+	// Cost is the weighted value of the term
+	Cost float64
+	// Violations are the offending objects, for the terms found by the design rule checks
+	Violations []Violation
+}
+
+// Breakdown details the evaluation of a pcb term by term.
+type Breakdown struct {
+	Terms []TermBreakdown
+	// Cost is the sum of the costs of the terms, before the fitness exponent
+	Cost    float64
+	Fitness float64
+}
+
+func (b *Breakdown) String() string {
+	sb := strings.Builder{}
+
+	for _, t := range b.Terms {
+		fmt.Fprintf(&sb, "%-16s %12.3f %12.3f\n", t.Name, t.Raw, t.Cost)
+	}
+
+	fmt.Fprintf(&sb, "%-16s %12s %12.3f, fitness %.3f\n", "total", "", b.Cost, b.Fitness)
+
+	return sb.String()
+}
+
+// Term returns the breakdown of the named term, nil if there is none.
+func (b *Breakdown) Term(name string) *TermBreakdown {
+	for i := range b.Terms {
+		if b.Terms[i].Name == name {
+			return &b.Terms[i]
+		}
+	}
+
+	return nil
+}
+
+// violationsTerm runs check on pcb and returns the breakdown of the violations it reports.
+func violationsTerm(name string, pcb *Pcb, check func(*Pcb, func(Violation))) TermBreakdown {
+	res := TermBreakdown{Name: name, Violations: make([]Violation, 0)}
+
+	check(pcb, func(v Violation) {
+		res.Violations = append(res.Violations, v)
+		res.Cost += v.Cost
+	})
+	res.Raw = float64(len(res.Violations))
+
+	return res
+}
+
+// EvaluateDetailed evaluates pcb, whose geometry must have been computed, and returns the cost of
+// every term along with the objects responsible for it when the term can tell them.
+func (pgo *PcbGeneticOperators) EvaluateDetailed(pcb *Pcb) *Breakdown {
+	res := &Breakdown{Terms: make([]TermBreakdown, len(pgo.costTerms))}
+
+	for i, t := range pgo.costTerms {
+		if dt, ok := t.(DetailedCostTerm); ok {
+			res.Terms[i] = dt.Breakdown(pcb)
+		} else {
+			raw := t.Evaluate(pcb)
+			res.Terms[i] = TermBreakdown{Name: t.Name(), Raw: raw, Cost: t.Weight() * raw}
+		}
+
+		res.Cost += res.Terms[i].Cost
+	}
+	res.Fitness = pgo.fitness(res.Cost)
+
+	return res
+}
+
+// Explain returns the breakdown of the evaluation of i, for the statistics of the genetic algorithm.
+func (pgo *PcbGeneticOperators) Explain(i *Pcb, c *genetic.GeneticContext) fmt.Stringer {
+	return pgo.EvaluateDetailed(i)
+}
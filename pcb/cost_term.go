@@ -0,0 +1,219 @@
+package pcb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CostTerm is an objective of the evaluation. The cost of a pcb is the sum over the terms of their
+// weight times their value, its fitness the opposite of the cost raised to the fitness exponent.
+type CostTerm interface {
+	Name() string
+	Weight() float64
+	// Evaluate returns the unweighted value of the term for pcb, whose geometry has been computed
+	Evaluate(pcb *Pcb) float64
+}
+
+// DetailedCostTerm is a cost term able to tell which objects its value comes from.
+type DetailedCostTerm interface {
+	CostTerm
+	Breakdown(pcb *Pcb) TermBreakdown
+}
+
+// CostTermFactory builds a term of the given weight for the operators. Params is the configuration
+// of the term in the problem, nil if it has none.
+type CostTermFactory func(pgo *PcbGeneticOperators, weight float64, params json.RawMessage) (CostTerm, error)
+
+// Names of the built-in terms, which every PcbGeneticOperators evaluates
+var builtinCostTerms = []string{"intersections", "edge length", "layers", "out of bounds", "keepouts", "unconnected", "vias", "board area", "hpwl", "mst length", "steiner length"}
+
+var costTermFactories = make(map[string]CostTermFactory)
+
+// RegisterCostTerm makes the term available to problems under name. It is meant to be called from
+// init functions, and panics if the name is already taken.
+func RegisterCostTerm(name string, factory CostTermFactory) {
+	if IsCostTermRegistered(name) {
+		panic(fmt.Sprintf("cost term %q is already registered", name))
+	}
+
+	if isBuiltinCostTerm(name) {
+		panic(fmt.Sprintf("cost term %q is built in", name))
+	}
+
+	costTermFactories[name] = factory
+}
+
+func isBuiltinCostTerm(name string) bool {
+	for _, n := range builtinCostTerms {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func IsCostTermRegistered(name string) bool {
+	_, ok := costTermFactories[name]
+	return ok
+}
+
+// RegisteredCostTerms returns the names of the registered terms, sorted.
+func RegisteredCostTerms() []string {
+	res := make([]string, 0, len(costTermFactories))
+	for name := range costTermFactories {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// NewCostTerm builds the registered term called name.
+func NewCostTerm(name string, pgo *PcbGeneticOperators, weight float64, params json.RawMessage) (CostTerm, error) {
+	factory, ok := costTermFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cost term %q", name)
+	}
+
+	return factory(pgo, weight, params)
+}
+
+// AddCostTerm adds a term to the evaluation, after the built-in ones.
+func (pgo *PcbGeneticOperators) AddCostTerm(t CostTerm) {
+	pgo.costTerms = append(pgo.costTerms, t)
+}
+
+// CostTerms returns the terms of the evaluation, the built-in ones first.
+func (pgo *PcbGeneticOperators) CostTerms() []CostTerm {
+	return pgo.costTerms
+}
+
+// checkTerm charges the violations reported by a design rule check, whose costs are already weighted.
+type checkTerm struct {
+	name  string
+	check func(*Pcb, func(Violation))
+}
+
+func (t *checkTerm) Name() string {
+	return t.name
+}
+
+func (t *checkTerm) Weight() float64 {
+	return 1
+}
+
+func (t *checkTerm) Evaluate(pcb *Pcb) float64 {
+	return sumCosts(pcb, t.check)
+}
+
+func (t *checkTerm) Breakdown(pcb *Pcb) TermBreakdown {
+	return violationsTerm(t.name, pcb, t.check)
+}
+
+// lengthTerm charges the length of the edges relative to the half perimeter of the board.
+type lengthTerm struct {
+	pgo *PcbGeneticOperators
+}
+
+func (t *lengthTerm) Name() string {
+	return "edge length"
+}
+
+func (t *lengthTerm) Weight() float64 {
+	return t.pgo.evaluationParams.EdgeLengthCost
+}
+
+func (t *lengthTerm) Evaluate(pcb *Pcb) float64 {
+	return GetTotalPcbLength(pcb) / (t.pgo.board.Width() + t.pgo.board.Height())
+}
+
+func (t *lengthTerm) Breakdown(pcb *Pcb) TermBreakdown {
+	return TermBreakdown{Name: t.Name(), Raw: GetTotalPcbLength(pcb), Cost: t.pgo.EvaluatePcbEdgeLengths(pcb)}
+}
+
+// layersTerm charges every edge the cost of the layer it is routed on. Its weight is the cost of
+// the most expensive layer and its value the number of edges, each counted by the cost of its
+// layer relative to that one: without a layer stack, edges outside plane 0 count 1 for a weight of
+// NonZeroPlaneEdgeCost.
+type layersTerm struct {
+	pgo *PcbGeneticOperators
+}
+
+func (t *layersTerm) Name() string {
+	return "layers"
+}
+
+func (t *layersTerm) Weight() float64 {
+	if len(t.pgo.board.Layers) == 0 {
+		return t.pgo.evaluationParams.NonZeroPlaneEdgeCost
+	}
+
+	res := 0.0
+
+	for _, l := range t.pgo.board.Layers {
+		res = math.Max(res, l.RoutingCost)
+
+		// Edges on layers their net can't use pay ForbiddenLayerCost instead
+		if l.AllowedNets != nil {
+			res = math.Max(res, t.pgo.evaluationParams.ForbiddenLayerCost)
+		}
+	}
+
+	return res
+}
+
+func (t *layersTerm) Evaluate(pcb *Pcb) float64 {
+	if len(t.pgo.board.Layers) == 0 {
+		return float64(t.pgo.getNonZeroPlaneEdgesCount(pcb))
+	}
+
+	// Every layer is free
+	weight := t.Weight()
+	if weight == 0 {
+		return 0
+	}
+
+	return t.pgo.EvaluateNonZeroPlaneEdges(pcb) / weight
+}
+
+func (t *layersTerm) Breakdown(pcb *Pcb) TermBreakdown {
+	raw := t.Evaluate(pcb)
+
+	return TermBreakdown{Name: t.Name(), Raw: raw, Cost: t.Weight() * raw}
+}
+
+// viasTerm charges every via.
+type viasTerm struct {
+	pgo *PcbGeneticOperators
+}
+
+func (t *viasTerm) Name() string {
+	return "vias"
+}
+
+func (t *viasTerm) Weight() float64 {
+	return t.pgo.evaluationParams.ViaCost
+}
+
+func (t *viasTerm) Evaluate(pcb *Pcb) float64 {
+	return float64(len(pcb.Geometry.Vias))
+}
+
+// builtinTerms returns the terms every evaluation has, in the order of builtinCostTerms.
+func (pgo *PcbGeneticOperators) builtinTerms() []CostTerm {
+	terms := []CostTerm{
+		&checkTerm{"intersections", pgo.checkIntersections},
+		&lengthTerm{pgo},
+		&layersTerm{pgo},
+		&checkTerm{"out of bounds", pgo.checkOutOfBounds},
+		&checkTerm{"keepouts", pgo.checkKeepouts},
+		&checkTerm{"unconnected", pgo.checkConnectivity},
+		&viasTerm{pgo},
+		&areaTerm{pgo},
+	}
+
+	return append(terms, pgo.wirelengthTerms()...)
+}
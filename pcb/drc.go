@@ -0,0 +1,507 @@
+package pcb
+
+import (
+	"fmt"
+	"genetic_pcb/geo"
+	"math"
+
+	"github.com/twpayne/go-geom"
+)
+
+type ViolationKind int
+
+const (
+	// Objects closer than their clearance
+	CLEARANCE_VIOLATION ViolationKind = iota
+	// Objects of different nets touching on the same plane
+	SHORT_VIOLATION
+	// Edges on different planes closer than their clearance
+	CROSSING_VIOLATION
+	// Component not entirely inside the board outline
+	OUT_OF_BOARD_VIOLATION
+	// Components on the same side closer than the minimum distance
+	COMPONENT_OVERLAP_VIOLATION
+	// Component or edge overlapping a keepout
+	KEEPOUT_VIOLATION
+	// Net whose edges don't connect all of its nodes
+	UNCONNECTED_VIOLATION
+)
+
+var violationKindNames = []string{
+	"clearance",
+	"short",
+	"crossing",
+	"out of board",
+	"component overlap",
+	"keepout",
+	"unconnected",
+}
+
+func (k ViolationKind) String() string {
+	return violationKindNames[k]
+}
+
+type ObjectKind int
+
+const (
+	NODE_OBJECT ObjectKind = iota
+	EDGE_OBJECT
+	COMPONENT_OBJECT
+	VIA_OBJECT
+	KEEPOUT_OBJECT
+	NET_OBJECT
+)
+
+var objectKindNames = []string{"node", "edge", "component", "via", "keepout", "net"}
+
+func (k ObjectKind) String() string {
+	return objectKindNames[k]
+}
+
+// Object identifies an element of a pcb or of its board by its index.
+type Object struct {
+	Kind  ObjectKind
+	Index int
+}
+
+func (o Object) String() string {
+	return fmt.Sprintf("%v %d", o.Kind, o.Index)
+}
+
+type Violation struct {
+	Kind    ViolationKind
+	Objects []Object
+	// X, Y locate the violation on the board
+	X, Y float64
+	// Measured and Required are the distance between the objects and the one the rules ask for,
+	// they are only meaningful for clearance, short, crossing and component overlap violations
+	Measured float64
+	Required float64
+	// Cost is what the violation adds to the evaluation
+	Cost float64
+}
+
+// boundsMidpoint returns the center of the overlap of the two bounds, or of the gap between them.
+func boundsMidpoint(b1, b2 *geom.Bounds) (float64, float64) {
+	x := (math.Max(b1.Min(0), b2.Min(0)) + math.Min(b1.Max(0), b2.Max(0))) / 2
+	y := (math.Max(b1.Min(1), b2.Min(1)) + math.Min(b1.Max(1), b2.Max(1))) / 2
+
+	return x, y
+}
+
+// pairViolation returns the kind of violation between two objects at distance dist, touching
+// objects of different nets on the same plane being shorted.
+func pairViolation(dist float64, net1, net2 int, samePlane bool) ViolationKind {
+	if !samePlane {
+		return CROSSING_VIOLATION
+	}
+
+	if dist == 0 && net1 != net2 {
+		return SHORT_VIOLATION
+	}
+
+	return CLEARANCE_VIOLATION
+}
+
+// CheckDesignRules returns every violation of the design rules of pcb, whose geometry must have
+// been computed. The evaluation cost terms are the sum of the costs of the violations it finds.
+func (pgo *PcbGeneticOperators) CheckDesignRules(pcb *Pcb) []Violation {
+	res := make([]Violation, 0)
+	report := func(v Violation) {
+		res = append(res, v)
+	}
+
+	pgo.checkIntersections(pcb, report)
+	pgo.checkOutOfBounds(pcb, report)
+	pgo.checkKeepouts(pcb, report)
+	pgo.checkConnectivity(pcb, report)
+
+	return res
+}
+
+// checkIntersections checks pads, edges and components pairwise. Pads only collide with the copper
+// on the planes they are on, so an edge can pass under an SMD pad of the other side but never
+// across a through-hole pad.
+func (pgo *PcbGeneticOperators) checkIntersections(pcb *Pcb, report func(Violation)) {
+	// Pairs of objects unchanged since the parent have the violations found in the parent
+	ch := pcb.changes
+	nodeNets := pcb.Genome.nodeNets()
+	nodePlanes := pcb.Genome.NodePlanes(pgo.geometryParams.Planes)
+
+	nodesBounds := make([]*geom.Bounds, len(pcb.Geometry.Nodes))
+	edgesBounds := make([]*geom.Bounds, len(pcb.Geometry.Edges))
+	componentsBounds := make([]*geom.Bounds, len(pcb.Geometry.Components))
+
+	for i, n := range pcb.Geometry.Nodes {
+		nodesBounds[i] = n.Bounds()
+	}
+
+	for i, e := range pcb.Geometry.Edges {
+		edgesBounds[i] = e.Bounds()
+	}
+
+	for i, c := range pcb.Geometry.Components {
+		componentsBounds[i] = c.Bounds()
+	}
+
+	// Only the pairs of objects sharing a cell of the spatial grids can be closer than the clearance
+	margin := pgo.maxClearance(pcb.Genome)
+	nodesGrid := geo.NewSpatialGrid(nodesBounds, margin)
+	edgesGrid := geo.NewSpatialGrid(edgesBounds, margin)
+	componentsGrid := geo.NewSpatialGrid(componentsBounds, pgo.evaluationParams.MinDist)
+
+	nodesGrid.Pairs(func(i1, i2 int) {
+		if ch.cleanPair(Object{NODE_OBJECT, i1}, Object{NODE_OBJECT, i2}) {
+			return
+		}
+
+		b1, b2 := nodesBounds[i1], nodesBounds[i2]
+		minDist := pgo.Clearance(pcb.Genome, nodeNets[i1], nodeNets[i2])
+
+		if boundsTooFar(b1, b2, minDist) || pcb.Genome.Nodes[i1].Component == pcb.Genome.Nodes[i2].Component {
+			return
+		}
+
+		if !sharePlane(nodePlanes[i1], nodePlanes[i2]) {
+			return
+		}
+
+		// Waypoints are copper of their net, they can touch anything of the same net
+		if nodeNets[i1] == nodeNets[i2] && (pcb.Genome.IsWaypoint(i1) || pcb.Genome.IsWaypoint(i2)) {
+			return
+		}
+
+		if dist := geo.PolyDistance(pcb.Geometry.Nodes[i1], pcb.Geometry.Nodes[i2]); dist < minDist {
+			x, y := boundsMidpoint(b1, b2)
+			report(Violation{
+				Kind:     pairViolation(dist, nodeNets[i1], nodeNets[i2], true),
+				Objects:  []Object{{NODE_OBJECT, i1}, {NODE_OBJECT, i2}},
+				X:        x,
+				Y:        y,
+				Measured: dist,
+				Required: minDist,
+				Cost:     pgo.evaluationParams.SamePlaneIntersectionCost,
+			})
+		}
+	})
+
+	edgesGrid.Pairs(func(i1, i2 int) {
+		if ch.cleanPair(Object{EDGE_OBJECT, i1}, Object{EDGE_OBJECT, i2}) {
+			return
+		}
+
+		b1, b2 := edgesBounds[i1], edgesBounds[i2]
+		edge1, edge2 := pcb.Genome.Edges[i1], pcb.Genome.Edges[i2]
+		minDist := pgo.Clearance(pcb.Genome, edge1.Net, edge2.Net)
+
+		if boundsTooFar(b1, b2, minDist) || pcb.Genome.AreAdjacent(i1, i2) {
+			return
+		}
+
+		// Edges lie within their reach of their paths, paths far enough apart need no polygon distance
+		if paths := pcb.Geometry.EdgePaths; len(paths) == len(pcb.Geometry.Edges) {
+			reach1 := pathReach(pcb.Genome.EdgeWidth(edge1.Net, pgo.geometryParams.EdgeSz), pgo.geometryParams.Style)
+			reach2 := pathReach(pcb.Genome.EdgeWidth(edge2.Net, pgo.geometryParams.EdgeSz), pgo.geometryParams.Style)
+			if geo.PolylineDistance(paths[i1], paths[i2])-reach1-reach2 >= minDist {
+				return
+			}
+		}
+
+		if dist := geo.PolyDistance(pcb.Geometry.Edges[i1], pcb.Geometry.Edges[i2]); dist < minDist {
+			cost := pgo.evaluationParams.SamePlaneIntersectionCost
+			if edge1.Plane != edge2.Plane {
+				cost = pgo.evaluationParams.DifferentPlaneIntersectionCost
+			}
+
+			x, y := boundsMidpoint(b1, b2)
+			report(Violation{
+				Kind:     pairViolation(dist, edge1.Net, edge2.Net, edge1.Plane == edge2.Plane),
+				Objects:  []Object{{EDGE_OBJECT, i1}, {EDGE_OBJECT, i2}},
+				X:        x,
+				Y:        y,
+				Measured: dist,
+				Required: minDist,
+				Cost:     cost,
+			})
+		}
+	})
+
+	for i1, n := range pcb.Geometry.Nodes {
+		b1 := nodesBounds[i1]
+		edgesGrid.Query(b1, func(i2 int) {
+			if ch.cleanPair(Object{NODE_OBJECT, i1}, Object{EDGE_OBJECT, i2}) {
+				return
+			}
+
+			b2 := edgesBounds[i2]
+			minDist := pgo.Clearance(pcb.Genome, nodeNets[i1], pcb.Genome.Edges[i2].Net)
+
+			if boundsTooFar(b1, b2, minDist) || pcb.Genome.IsNodeOnEdge(i1, i2) {
+				return
+			}
+
+			if plane := pcb.Genome.Edges[i2].Plane; plane < len(nodePlanes[i1]) && !nodePlanes[i1][plane] {
+				return
+			}
+
+			if nodeNets[i1] == pcb.Genome.Edges[i2].Net && pcb.Genome.IsWaypoint(i1) {
+				return
+			}
+
+			// The node lies in the circle around the center of its bounds, the edge within its reach of its path
+			if paths := pcb.Geometry.EdgePaths; len(paths) == len(pcb.Geometry.Edges) {
+				center := geom.Coord{(b1.Min(0) + b1.Max(0)) / 2, (b1.Min(1) + b1.Max(1)) / 2}
+				radius := math.Hypot(b1.Max(0)-b1.Min(0), b1.Max(1)-b1.Min(1)) / 2
+				reach := pathReach(pcb.Genome.EdgeWidth(pcb.Genome.Edges[i2].Net, pgo.geometryParams.EdgeSz), pgo.geometryParams.Style)
+
+				if geo.PointToPolylineDist(center, paths[i2])-radius-reach >= minDist {
+					return
+				}
+			}
+
+			if dist := geo.PolyDistance(n, pcb.Geometry.Edges[i2]); dist < minDist {
+				x, y := boundsMidpoint(b1, b2)
+				report(Violation{
+					Kind:     pairViolation(dist, nodeNets[i1], pcb.Genome.Edges[i2].Net, true),
+					Objects:  []Object{{NODE_OBJECT, i1}, {EDGE_OBJECT, i2}},
+					X:        x,
+					Y:        y,
+					Measured: dist,
+					Required: minDist,
+					Cost:     pgo.evaluationParams.SamePlaneIntersectionCost,
+				})
+			}
+		})
+	}
+
+	componentsGrid.Pairs(func(i1, i2 int) {
+		if ch.cleanPair(Object{COMPONENT_OBJECT, i1}, Object{COMPONENT_OBJECT, i2}) {
+			return
+		}
+
+		b1, b2 := componentsBounds[i1], componentsBounds[i2]
+
+		if boundsTooFar(b1, b2, pgo.evaluationParams.MinDist) || pcb.Genome.Components[i1].Side != pcb.Genome.Components[i2].Side {
+			return
+		}
+
+		if pcb.Genome.Components[i1].Kind == EDGE_BREAKER_COMPONENT || pcb.Genome.Components[i2].Kind == EDGE_BREAKER_COMPONENT {
+			return
+		}
+
+		if dist := geo.PolyDistance(pcb.Geometry.Components[i1], pcb.Geometry.Components[i2]); dist < pgo.evaluationParams.MinDist {
+			x, y := boundsMidpoint(b1, b2)
+			report(Violation{
+				Kind:     COMPONENT_OVERLAP_VIOLATION,
+				Objects:  []Object{{COMPONENT_OBJECT, i1}, {COMPONENT_OBJECT, i2}},
+				X:        x,
+				Y:        y,
+				Measured: dist,
+				Required: pgo.evaluationParams.MinDist,
+				// Component pairs have always been charged once for each of the two components
+				Cost: 2 * pgo.evaluationParams.SamePlaneIntersectionCost,
+			})
+		}
+	})
+
+	// Vias are recomputed along with the geometry, they are always checked again
+	ch.replay(report, CLEARANCE_VIOLATION, SHORT_VIOLATION, CROSSING_VIOLATION, COMPONENT_OVERLAP_VIOLATION)
+	pgo.checkViaClearances(pcb, nodeNets, nodesBounds, edgesBounds, nodesGrid, edgesGrid, report)
+}
+
+// checkViaClearances checks vias, which cross every plane, against pads, edges and vias of other nets.
+func (pgo *PcbGeneticOperators) checkViaClearances(pcb *Pcb, nodeNets []int, nodesBounds, edgesBounds []*geom.Bounds, nodesGrid, edgesGrid *geo.SpatialGrid, report func(Violation)) {
+	viaViolation := func(via int, other Object, otherNet int, b1, b2 *geom.Bounds, dist, minDist float64) Violation {
+		x, y := boundsMidpoint(b1, b2)
+
+		return Violation{
+			Kind:     pairViolation(dist, pcb.Geometry.ViaSites[via].Net, otherNet, true),
+			Objects:  []Object{{VIA_OBJECT, via}, other},
+			X:        x,
+			Y:        y,
+			Measured: dist,
+			Required: minDist,
+			Cost:     pgo.evaluationParams.SamePlaneIntersectionCost,
+		}
+	}
+
+	for i1, v := range pcb.Geometry.Vias {
+		site := pcb.Geometry.ViaSites[i1]
+		b1 := v.Bounds()
+
+		nodesGrid.Query(b1, func(i2 int) {
+			minDist := pgo.Clearance(pcb.Genome, site.Net, nodeNets[i2])
+
+			if nodeNets[i2] == site.Net || boundsTooFar(b1, nodesBounds[i2], minDist) {
+				return
+			}
+
+			if dist := geo.PolyDistance(v, pcb.Geometry.Nodes[i2]); dist < minDist {
+				report(viaViolation(i1, Object{NODE_OBJECT, i2}, nodeNets[i2], b1, nodesBounds[i2], dist, minDist))
+			}
+		})
+
+		edgesGrid.Query(b1, func(i2 int) {
+			minDist := pgo.Clearance(pcb.Genome, site.Net, pcb.Genome.Edges[i2].Net)
+
+			if pcb.Genome.Edges[i2].Net == site.Net || boundsTooFar(b1, edgesBounds[i2], minDist) {
+				return
+			}
+
+			if dist := geo.PolyDistance(v, pcb.Geometry.Edges[i2]); dist < minDist {
+				report(viaViolation(i1, Object{EDGE_OBJECT, i2}, pcb.Genome.Edges[i2].Net, b1, edgesBounds[i2], dist, minDist))
+			}
+		})
+
+		for i2 := i1 + 1; i2 < len(pcb.Geometry.Vias); i2++ {
+			if pcb.Geometry.ViaSites[i2].Net == site.Net {
+				continue
+			}
+
+			minDist := pgo.Clearance(pcb.Genome, site.Net, pcb.Geometry.ViaSites[i2].Net)
+
+			if dist := geo.PolyDistance(v, pcb.Geometry.Vias[i2]); dist < minDist {
+				report(viaViolation(i1, Object{VIA_OBJECT, i2}, pcb.Geometry.ViaSites[i2].Net, b1, pcb.Geometry.Vias[i2].Bounds(), dist, minDist))
+			}
+		}
+	}
+}
+
+func (pgo *PcbGeneticOperators) checkOutOfBounds(pcb *Pcb, report func(Violation)) {
+	ch := pcb.changes
+	ch.replay(report, OUT_OF_BOARD_VIOLATION)
+
+	for i, c := range pcb.Geometry.Components {
+		if ch != nil && !ch.dirty(Object{COMPONENT_OBJECT, i}) {
+			continue
+		}
+
+		component := &pcb.Genome.Components[i]
+		inside := pgo.board.Contains(c)
+
+		// Waypoints only need their position inside the board
+		if component.Kind == EDGE_BREAKER_COMPONENT {
+			inside = pgo.board.ContainsPoint(component.CX, component.CY)
+		}
+
+		if !inside {
+			report(Violation{
+				Kind:    OUT_OF_BOARD_VIOLATION,
+				Objects: []Object{{COMPONENT_OBJECT, i}},
+				X:       component.CX,
+				Y:       component.CY,
+				Cost:    pgo.evaluationParams.OutOfBoundsCost,
+			})
+		}
+	}
+}
+
+func (pgo *PcbGeneticOperators) checkKeepouts(pcb *Pcb, report func(Violation)) {
+	ch := pcb.changes
+	ch.replay(report, KEEPOUT_VIOLATION)
+
+	for ik, k := range pgo.board.Keepouts {
+		kb := k.Area.Bounds()
+
+		switch k.Kind {
+		case COMPONENT_KEEPOUT:
+			for i, c := range pcb.Geometry.Components {
+				if pcb.Genome.Components[i].Kind == EDGE_BREAKER_COMPONENT || ch.cleanPair(Object{KEEPOUT_OBJECT, ik}, Object{COMPONENT_OBJECT, i}) {
+					continue
+				}
+
+				if !boundsTooFar(kb, c.Bounds(), 0) && geo.PolyDistance(k.Area, c) == 0 {
+					x, y := boundsMidpoint(kb, c.Bounds())
+					report(Violation{
+						Kind:    KEEPOUT_VIOLATION,
+						Objects: []Object{{KEEPOUT_OBJECT, ik}, {COMPONENT_OBJECT, i}},
+						X:       x,
+						Y:       y,
+						Cost:    pgo.evaluationParams.ComponentKeepoutCost,
+					})
+				}
+			}
+		case ROUTING_KEEPOUT:
+			for i, e := range pcb.Geometry.Edges {
+				if ch.cleanPair(Object{KEEPOUT_OBJECT, ik}, Object{EDGE_OBJECT, i}) {
+					continue
+				}
+
+				if pcb.Genome.Edges[i].Plane == k.Plane && !boundsTooFar(kb, e.Bounds(), 0) && geo.PolyDistance(k.Area, e) == 0 {
+					x, y := boundsMidpoint(kb, e.Bounds())
+					report(Violation{
+						Kind:    KEEPOUT_VIOLATION,
+						Objects: []Object{{KEEPOUT_OBJECT, ik}, {EDGE_OBJECT, i}},
+						X:       x,
+						Y:       y,
+						Cost:    pgo.evaluationParams.RoutingKeepoutCost,
+					})
+				}
+			}
+		}
+	}
+}
+
+// checkConnectivity reports the nets whose edges leave some of their nodes apart, listing the
+// nodes not connected to the first one.
+func (pgo *PcbGeneticOperators) checkConnectivity(pcb *Pcb, report func(Violation)) {
+	parent := make([]int, len(pcb.Genome.Nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(n int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+
+	for _, e := range pcb.Genome.Edges {
+		parent[find(e.From)] = find(e.To)
+	}
+
+	if pgo.board != nil {
+		pcb.zoneConnections(pgo.board.Zones, pgo.geometryParams.Planes, func(n1, n2 int) {
+			parent[find(n1)] = find(n2)
+		})
+	}
+
+	for i, n := range pcb.Genome.Nets {
+		if len(n.Nodes) < 2 {
+			continue
+		}
+
+		objects := []Object{{NET_OBJECT, i}}
+		root := find(n.Nodes[0])
+
+		for _, node := range n.Nodes[1:] {
+			if find(node) != root {
+				objects = append(objects, Object{NODE_OBJECT, node})
+			}
+		}
+
+		if len(objects) > 1 {
+			first := pcb.Genome.Nodes[objects[1].Index]
+			report(Violation{
+				Kind:    UNCONNECTED_VIOLATION,
+				Objects: objects,
+				X:       first.X,
+				Y:       first.Y,
+				Cost:    pgo.evaluationParams.UnconnectedCost,
+			})
+		}
+	}
+}
+
+// sumCosts runs check on pcb and returns the total cost of the violations it reports.
+func sumCosts(pcb *Pcb, check func(*Pcb, func(Violation))) float64 {
+	cost := 0.0
+
+	check(pcb, func(v Violation) {
+		cost += v.Cost
+	})
+
+	return cost
+}
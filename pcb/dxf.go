@@ -0,0 +1,162 @@
+package pcb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/twpayne/go-geom"
+)
+
+const (
+	DXF_BOARD_LAYER     = "BOARD"
+	DXF_COMPONENT_LAYER = "COMPONENTS"
+	DXF_PAD_LAYER       = "PADS"
+	DXF_VIA_LAYER       = "VIAS"
+)
+
+// DxfTraceLayer returns the name of the DXF layer holding the traces of the given plane.
+func DxfTraceLayer(plane int) string {
+	return fmt.Sprintf("TRACES_%d", plane)
+}
+
+type dxfWriter struct {
+	w         *bufio.Writer
+	maxY      float64
+	unitsToMm float64
+}
+
+func (dw *dxfWriter) pair(code int, value interface{}) {
+	fmt.Fprintf(dw.w, "%d\n%v\n", code, value)
+}
+
+func (dw *dxfWriter) layer(name string, color int) {
+	dw.pair(0, "LAYER")
+	dw.pair(2, name)
+	dw.pair(70, 0)
+	dw.pair(62, color)
+	dw.pair(6, "CONTINUOUS")
+}
+
+// polygon writes every ring of p as a closed POLYLINE. DXF has the Y axis pointing up,
+// so coordinates are flipped around the board height.
+func (dw *dxfWriter) polygon(layer string, p *geom.Polygon) {
+	for _, ring := range p.Coords() {
+		dw.pair(0, "POLYLINE")
+		dw.pair(8, layer)
+		dw.pair(66, 1)
+		dw.pair(10, 0.0)
+		dw.pair(20, 0.0)
+		dw.pair(30, 0.0)
+		dw.pair(70, 1)
+
+		// Rings repeat their first point at the end, closed polylines must not
+		for _, pt := range ring[:len(ring)-1] {
+			dw.pair(0, "VERTEX")
+			dw.pair(8, layer)
+			dw.pair(10, fmt.Sprintf("%.4f", pt.X()*dw.unitsToMm))
+			dw.pair(20, fmt.Sprintf("%.4f", (dw.maxY-pt.Y())*dw.unitsToMm))
+			dw.pair(30, 0.0)
+		}
+
+		dw.pair(0, "SEQEND")
+		dw.pair(8, layer)
+	}
+}
+
+// WritePcbDxf writes the board outline, component outlines, pads, vias and traces as an R12 ASCII DXF,
+// one layer per kind of object and one trace layer per plane. unitsToMm converts pcb units to millimetres.
+func WritePcbDxf(w io.Writer, pcb *Pcb, board *Board, unitsToMm float64) error {
+	dw := &dxfWriter{w: bufio.NewWriter(w), maxY: board.Bounds().Max(1), unitsToMm: unitsToMm}
+	planes := pcb.Genome.usedPlanes(board.Zones)
+
+	dw.pair(0, "SECTION")
+	dw.pair(2, "HEADER")
+	dw.pair(9, "$ACADVER")
+	dw.pair(1, "AC1009")
+	dw.pair(9, "$INSUNITS")
+	dw.pair(70, 4)
+	dw.pair(9, "$MEASUREMENT")
+	dw.pair(70, 1)
+	dw.pair(0, "ENDSEC")
+
+	dw.pair(0, "SECTION")
+	dw.pair(2, "TABLES")
+	dw.pair(0, "TABLE")
+	dw.pair(2, "LTYPE")
+	dw.pair(70, 1)
+	dw.pair(0, "LTYPE")
+	dw.pair(2, "CONTINUOUS")
+	dw.pair(70, 0)
+	dw.pair(3, "Solid line")
+	dw.pair(72, 65)
+	dw.pair(73, 0)
+	dw.pair(40, 0.0)
+	dw.pair(0, "ENDTAB")
+	dw.pair(0, "TABLE")
+	dw.pair(2, "LAYER")
+	dw.pair(70, 4+len(planes))
+	dw.layer(DXF_BOARD_LAYER, 7)
+	dw.layer(DXF_COMPONENT_LAYER, 3)
+	dw.layer(DXF_PAD_LAYER, 1)
+	dw.layer(DXF_VIA_LAYER, 8)
+
+	for _, plane := range planes {
+		dw.layer(DxfTraceLayer(plane), 4+plane)
+	}
+
+	dw.pair(0, "ENDTAB")
+	dw.pair(0, "ENDSEC")
+
+	dw.pair(0, "SECTION")
+	dw.pair(2, "ENTITIES")
+
+	dw.polygon(DXF_BOARD_LAYER, board.Outline)
+
+	for i, c := range pcb.Geometry.Components {
+		if pcb.Genome.Components[i].Kind != EDGE_BREAKER_COMPONENT {
+			dw.polygon(DXF_COMPONENT_LAYER, c)
+		}
+	}
+
+	// Waypoints are trace bends, not pads
+	for i, n := range pcb.Geometry.Nodes {
+		if !pcb.Genome.IsWaypoint(i) {
+			dw.polygon(DXF_PAD_LAYER, n)
+		}
+	}
+
+	for _, v := range pcb.Geometry.Vias {
+		dw.polygon(DXF_VIA_LAYER, v)
+	}
+
+	for i, fill := range pcb.Geometry.ZoneFills {
+		for _, p := range fill {
+			dw.polygon(DxfTraceLayer(board.Zones[i].Plane), p)
+		}
+	}
+
+	for i, e := range pcb.Geometry.Edges {
+		dw.polygon(DxfTraceLayer(pcb.Genome.Edges[i].Plane), e)
+	}
+
+	dw.pair(0, "ENDSEC")
+	dw.pair(0, "EOF")
+
+	return dw.w.Flush()
+}
+
+func DrawPcbToDxf(pcb *Pcb, board *Board, dxfPath string, unitsToMm float64) error {
+	f, err := os.Create(dxfPath)
+	if err != nil {
+		return err
+	}
+
+	if err := WritePcbDxf(f, pcb, board, unitsToMm); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,114 @@
+package pcb_test
+
+import (
+	"bufio"
+	"bytes"
+	"genetic_pcb/pcb"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type dxfPolyline struct {
+	layer    string
+	vertices [][2]float64
+}
+
+// parseDxfPolylines reads the group code/value pairs of an ASCII DXF and returns its POLYLINE entities.
+func parseDxfPolylines(t *testing.T, src string) []dxfPolyline {
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	res := make([]dxfPolyline, 0)
+
+	var entity string
+	var current *dxfPolyline
+
+	for scanner.Scan() {
+		code, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			t.Fatalf("Invalid group code %q", scanner.Text())
+		}
+
+		if !scanner.Scan() {
+			t.Fatalf("Missing value for group code %d", code)
+		}
+		value := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case code == 0:
+			entity = value
+			if value == "POLYLINE" {
+				res = append(res, dxfPolyline{})
+				current = &res[len(res)-1]
+			} else if value == "VERTEX" {
+				current.vertices = append(current.vertices, [2]float64{})
+			}
+		case code == 8 && entity == "POLYLINE":
+			current.layer = value
+		case (code == 10 || code == 20) && entity == "VERTEX":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("Invalid coordinate %q", value)
+			}
+			current.vertices[len(current.vertices)-1][code/10-1] = v
+		}
+	}
+
+	if entity != "EOF" {
+		t.Errorf("DXF does not end with EOF")
+	}
+
+	return res
+}
+
+func TestWritePcbDxf(t *testing.T) {
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10, Component: 0},
+			{X: 50, Y: 10, Component: 1},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0, Plane: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}},
+		Components: []pcb.Component{
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 10, CY: 10},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 50, CY: 10},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	buf := bytes.Buffer{}
+	if err := pcb.WritePcbDxf(&buf, p, pcb.NewRectangularBoard(100, 80), 0.1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	polylines := parseDxfPolylines(t, buf.String())
+	layers := make(map[string]int)
+
+	for _, pl := range polylines {
+		layers[pl.layer]++
+
+		if len(pl.vertices) != 4 {
+			t.Errorf("Expected 4 vertices on layer %s, got %d", pl.layer, len(pl.vertices))
+		}
+	}
+
+	expected := map[string]int{
+		pcb.DXF_BOARD_LAYER:     1,
+		pcb.DXF_COMPONENT_LAYER: 2,
+		pcb.DXF_PAD_LAYER:       2,
+		pcb.DxfTraceLayer(1):    1,
+	}
+
+	for layer, n := range expected {
+		if layers[layer] != n {
+			t.Errorf("Expected %d polylines on layer %s, got %d", n, layer, layers[layer])
+		}
+	}
+
+	// Board corners in millimetres, with the Y axis flipped
+	board := polylines[0].vertices
+	if board[0] != [2]float64{0, 8} || board[2] != [2]float64{10, 0} {
+		t.Errorf("Unexpected board outline %v", board)
+	}
+}
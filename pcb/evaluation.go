@@ -1,18 +1,33 @@
 package pcb
 
 import (
-	"genetic_pcb/geo"
+	"math"
 
 	"github.com/twpayne/go-geom"
 )
 
 type EvaluationParams struct {
-	SamePlaneIntersectionCost      float64
-	DifferentPlaneIntersectionCost float64
-	EdgeLengthCost                 float64
-	OutOfBoundsCost                float64
-	NonZeroPlaneEdgeCost           float64
-	MinDist                        float64
+	SamePlaneIntersectionCost      float64 `json:"samePlaneIntersectionCost"`
+	DifferentPlaneIntersectionCost float64 `json:"differentPlaneIntersectionCost"`
+	EdgeLengthCost                 float64 `json:"edgeLengthCost"`
+	OutOfBoundsCost                float64 `json:"outOfBoundsCost"`
+	NonZeroPlaneEdgeCost           float64 `json:"nonZeroPlaneEdgeCost"`
+	MinDist                        float64 `json:"minDist"`
+	ComponentKeepoutCost           float64 `json:"componentKeepoutCost"`
+	ViaCost                        float64 `json:"viaCost"`
+	ForbiddenLayerCost             float64 `json:"forbiddenLayerCost"`
+	RoutingKeepoutCost             float64 `json:"routingKeepoutCost"`
+	UnconnectedCost                float64 `json:"unconnectedCost"`
+	// BoardAreaCost weighs the area of the tight outline of the pcb relative to the one of the board
+	BoardAreaCost float64 `json:"boardAreaCost"`
+	// BoardAreaHull measures the convex hull of the pcb instead of its bounding rectangle
+	BoardAreaHull bool `json:"boardAreaHull"`
+	// HpwlCost, MstLengthCost and SteinerLengthCost weigh estimates of the length of the nets from
+	// the positions of their pads, relative to the half perimeter of the board: the half perimeter
+	// of their bounding boxes, the length of their minimum spanning trees and of their Steiner trees
+	HpwlCost          float64 `json:"hpwlCost"`
+	MstLengthCost     float64 `json:"mstLengthCost"`
+	SteinerLengthCost float64 `json:"steinerLengthCost"`
 }
 
 func boundsTooFar(b1 *geom.Bounds, b2 *geom.Bounds, minDist float64) bool {
@@ -35,104 +50,40 @@ func boundsTooFar(b1 *geom.Bounds, b2 *geom.Bounds, minDist float64) bool {
 	return false
 }
 
-func (pgo *PcbGeneticOperators) EvaluatePcbIntersections(pcb *Pcb) float64 {
-
-	cost := 0.0
-
-	nodesBounds := make([]*geom.Bounds, len(pcb.Geometry.Nodes))
-	edgesBounds := make([]*geom.Bounds, len(pcb.Geometry.Edges))
-	componentsBounds := make([]*geom.Bounds, len(pcb.Geometry.Components))
-
-	for i, n := range pcb.Geometry.Nodes {
-		nodesBounds[i] = n.Bounds()
-	}
-
-	for i, e := range pcb.Geometry.Edges {
-		edgesBounds[i] = e.Bounds()
-	}
-
-	for i, c := range pcb.Geometry.Components {
-		componentsBounds[i] = c.Bounds()
-	}
-
-	for i1, n1 := range pcb.Geometry.Nodes {
-		b1 := nodesBounds[i1]
-		for i2, n2 := range pcb.Geometry.Nodes {
-			b2 := nodesBounds[i2]
-
-			if boundsTooFar(b1, b2, pgo.evaluationParams.MinDist) {
-				continue
-			}
-
-			if i1 != i2 && pcb.Genome.Nodes[i1].Component != pcb.Genome.Nodes[i2].Component {
-				if geo.PolyDistance(n1, n2) < pgo.evaluationParams.MinDist {
-					// fmt.Printf("nn %v %v\n", i1, i2)
-					cost += pgo.evaluationParams.SamePlaneIntersectionCost / 2.0
-				}
-			}
-		}
-	}
-
-	for i1, e1 := range pcb.Geometry.Edges {
-		b1 := edgesBounds[i1]
-		for i2, e2 := range pcb.Geometry.Edges {
-			b2 := edgesBounds[i2]
-
-			if boundsTooFar(b1, b2, pgo.evaluationParams.MinDist) {
-				continue
-			}
-
-			if i1 != i2 {
-				if !(pcb.Genome.AreAdjacent(i1, i2)) && geo.PolyDistance(e1, e2) < pgo.evaluationParams.MinDist {
-					if pcb.Genome.Edges[i1].Plane == pcb.Genome.Edges[i2].Plane {
-						cost += pgo.evaluationParams.SamePlaneIntersectionCost / 2.0
-					} else {
-						cost += pgo.evaluationParams.DifferentPlaneIntersectionCost / 2.0
-					}
-
-				}
-			}
-		}
-	}
-
-	for i1, n := range pcb.Geometry.Nodes {
-		b1 := nodesBounds[i1]
-		for i2, e := range pcb.Geometry.Edges {
-			b2 := edgesBounds[i2]
-
-			if boundsTooFar(b1, b2, pgo.evaluationParams.MinDist) {
-				continue
-			}
-
-			if !(pcb.Genome.IsNodeOnEdge(i1, i2)) && geo.PolyDistance(n, e) < pgo.evaluationParams.MinDist {
-				// fmt.Printf("ne %v %v %v %v\n", i1, i2, geo.PolyDistance(n, e), pcb.Genome.Edges[i2])
-				cost += pgo.evaluationParams.SamePlaneIntersectionCost
-			}
-		}
+// Clearance returns the minimum distance between objects of the two nets, the largest of their
+// classes clearances. Objects not belonging to any net use EvaluationParams.MinDist.
+func (pgo *PcbGeneticOperators) Clearance(g *Genome, net1, net2 int) float64 {
+	c1, c2 := g.NetClass(net1), g.NetClass(net2)
+
+	switch {
+	case c1 == nil && c2 == nil:
+		return pgo.evaluationParams.MinDist
+	case c1 == nil:
+		return c2.Clearance
+	case c2 == nil:
+		return c1.Clearance
 	}
 
-	for i1, c1 := range pcb.Geometry.Components {
-		b1 := componentsBounds[i1]
-		for i2, c2 := range pcb.Geometry.Components {
-			b2 := componentsBounds[i2]
+	return math.Max(c1.Clearance, c2.Clearance)
+}
 
-			if boundsTooFar(b1, b2, pgo.evaluationParams.MinDist) {
-				continue
-			}
+// maxClearance returns the largest clearance between any two nets of g.
+func (pgo *PcbGeneticOperators) maxClearance(g *Genome) float64 {
+	res := pgo.evaluationParams.MinDist
 
-			if i1 != i2 && geo.PolyDistance(c1, c2) < pgo.evaluationParams.MinDist {
-				// fmt.Printf("ne %v %v %v %v\n", i1, i2, geo.PolyDistance(n, e), pcb.Genome.Edges[i2])
-				cost += pgo.evaluationParams.SamePlaneIntersectionCost
-			}
-		}
+	for _, c := range g.NetClasses {
+		res = math.Max(res, c.Clearance)
 	}
 
-	return cost
+	return res
+}
 
+func (pgo *PcbGeneticOperators) EvaluatePcbIntersections(pcb *Pcb) float64 {
+	return sumCosts(pcb, pgo.checkIntersections)
 }
 
 func (pgo *PcbGeneticOperators) EvaluatePcbEdgeLengths(pcb *Pcb) float64 {
-	return (GetTotalPcbLength(pcb) / (pgo.maxX + pgo.maxY)) * pgo.evaluationParams.EdgeLengthCost
+	return (GetTotalPcbLength(pcb) / (pgo.board.Width() + pgo.board.Height())) * pgo.evaluationParams.EdgeLengthCost
 }
 
 func (pgo *PcbGeneticOperators) getNonZeroPlaneEdgesCount(i *Pcb) int {
@@ -147,27 +98,39 @@ func (pgo *PcbGeneticOperators) getNonZeroPlaneEdgesCount(i *Pcb) int {
 	return cost
 }
 
+// EvaluateNonZeroPlaneEdges charges the routing cost of the layer of every edge, or NonZeroPlaneEdgeCost
+// for edges outside plane 0 on boards without a layer stack. Edges on planes their net can't use
+// pay ForbiddenLayerCost.
 func (pgo *PcbGeneticOperators) EvaluateNonZeroPlaneEdges(pcb *Pcb) float64 {
-	return float64(pgo.getNonZeroPlaneEdgesCount(pcb)) * pgo.evaluationParams.NonZeroPlaneEdgeCost
-}
-
-func (pgo *PcbGeneticOperators) EvaluateComponentsOutOfBounds(pcb *Pcb) float64 {
+	if len(pgo.board.Layers) == 0 {
+		return float64(pgo.getNonZeroPlaneEdgesCount(pcb)) * pgo.evaluationParams.NonZeroPlaneEdgeCost
+	}
 
 	cost := 0.0
 
-	pcbSpace := geom.NewPolygonFlat(geom.XY, []float64{
-		0, 0,
-		pgo.maxX, 0,
-		pgo.maxX, pgo.maxY,
-		0, pgo.maxY,
-		0, 0,
-	}, []int{10})
-
-	for _, c := range pcb.Geometry.Components {
-		if !geo.IsContained(c, pcbSpace) {
-			cost += pgo.evaluationParams.OutOfBoundsCost
+	for _, e := range pcb.Genome.Edges {
+		if !pgo.board.IsNetAllowed(e.Plane, e.Net) {
+			cost += pgo.evaluationParams.ForbiddenLayerCost
+		} else {
+			cost += pgo.board.Layers[e.Plane].RoutingCost
 		}
 	}
 
 	return cost
 }
+
+func (pgo *PcbGeneticOperators) EvaluateComponentsOutOfBounds(pcb *Pcb) float64 {
+	return sumCosts(pcb, pgo.checkOutOfBounds)
+}
+
+func (pgo *PcbGeneticOperators) EvaluateKeepouts(pcb *Pcb) float64 {
+	return sumCosts(pcb, pgo.checkKeepouts)
+}
+
+func (pgo *PcbGeneticOperators) EvaluateUnconnectedNets(pcb *Pcb) float64 {
+	return sumCosts(pcb, pgo.checkConnectivity)
+}
+
+func (pgo *PcbGeneticOperators) EvaluateVias(pcb *Pcb) float64 {
+	return float64(len(pcb.Geometry.Vias)) * pgo.evaluationParams.ViaCost
+}
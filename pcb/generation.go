@@ -70,6 +70,10 @@ func GeneratePcbWithNets(netSz, netN int, maxX, maxY float64) *Pcb {
 }
 
 func GeneratePcbFull(componentTemplates []Component, componentN, netN int, maxX, maxY float64, randomGenerator *rand.Rand) *Pcb {
+	return GeneratePcbFullOnBoard(componentTemplates, componentN, netN, NewRectangularBoard(maxX, maxY), randomGenerator)
+}
+
+func GeneratePcbFullOnBoard(componentTemplates []Component, componentN, netN int, board *Board, randomGenerator *rand.Rand) *Pcb {
 	nodeI := 0
 
 	pcb := &Pcb{
@@ -93,7 +97,8 @@ func GeneratePcbFull(componentTemplates []Component, componentN, netN int, maxX,
 
 		}
 
-		c.CX, c.CY = GetComponentRandomPositionInBoundaries(c, maxX, maxY, randomGenerator)
+		RandomizeComponentRotation(c, board, randomGenerator)
+		RandomizeComponentPosition(c, board, randomGenerator)
 		PlaceComponentNodes(pcb.Genome.Nodes, c)
 
 		pcb.Genome.Components[i] = *c
@@ -116,6 +121,10 @@ func GeneratePcbFull(componentTemplates []Component, componentN, netN int, maxX,
 }
 
 func ScrumblePcb(original *Pcb, maxX, maxY float64) *Pcb {
+	return ScrumblePcbOnBoard(original, NewRectangularBoard(maxX, maxY))
+}
+
+func ScrumblePcbOnBoard(original *Pcb, board *Board) *Pcb {
 	res := original.Genome.copy()
 
 	copy(res.Edges, original.Genome.Edges)
@@ -123,9 +132,11 @@ func ScrumblePcb(original *Pcb, maxX, maxY float64) *Pcb {
 	s1 := rand.NewSource(time.Now().UnixNano())
 	randomGenerator := rand.New(s1)
 
-	for i := 0; i < len(res.Components); i++ {
+	// Waypoints are left where they are, they belong to the routing
+	for i := 0; i < res.realComponents(); i++ {
 		c := &res.Components[i]
-		c.CX, c.CY = GetComponentRandomPositionInBoundaries(c, maxX, maxY, randomGenerator)
+		RandomizeComponentRotation(c, board, randomGenerator)
+		RandomizeComponentPosition(c, board, randomGenerator)
 		PlaceComponentNodes(res.Nodes, c)
 	}
 
@@ -1,6 +1,7 @@
 package pcb
 
 import (
+	"fmt"
 	"genetic_pcb/genetic"
 	"math"
 )
@@ -9,14 +10,15 @@ type PcbGeneticOperators struct {
 	fitnessExp                float64
 	mutateProb                float64
 	mutateSingleComponentProb float64
-	maxX                      float64
-	maxY                      float64
-	nodeSz                    float64
-	edgeSz                    float64
+	board                     *Board
+	geometryParams            GeometryParams
 	localMutationMaxDelta     float64
 	mutationParams            MutationParams
 	evaluationParams          EvaluationParams
-	mutationChooser           mutationChooser
+	mutationChooser           *mutationChooser
+	costTerms                 []CostTerm
+	// verifyIncremental makes Evaluate check the incremental evaluation of children against a full one
+	verifyIncremental bool
 }
 
 func NewPcbGeneticOperators(
@@ -36,28 +38,97 @@ func NewPcbGeneticOperators(
 		fitnessExp:                fitnessExp,
 		mutateProb:                mutateProb,
 		mutateSingleComponentProb: mutateSinglePointProb,
-		maxX:                      maxX,
-		maxY:                      maxY,
-		nodeSz:                    nodeSz,
-		edgeSz:                    edgeSz,
+		board:                     NewRectangularBoard(maxX, maxY),
+		geometryParams:            GeometryParams{NodeSz: nodeSz, EdgeSz: edgeSz, ViaSz: nodeSz, Planes: DEFAULT_PLANES},
 		localMutationMaxDelta:     localMutationMaxDelta,
 		mutationParams:            mutationParams,
 		evaluationParams:          evaluationParams,
 	}
 
-	pgo.mutationChooser = *pgo.buildMutationChooser()
+	pgo.mutationChooser = pgo.buildMutationChooser()
+	pgo.costTerms = pgo.builtinTerms()
 
 	return &pgo
 }
 
+// SetBoard replaces the maxX x maxY rectangle given to NewPcbGeneticOperators with an arbitrary outline.
+func (pgo *PcbGeneticOperators) SetBoard(board *Board) {
+	pgo.board = board
+	pgo.geometryParams.Planes = board.NumPlanes()
+	pgo.geometryParams.Board = board
+}
+
+func (pgo *PcbGeneticOperators) Board() *Board {
+	return pgo.board
+}
+
+// SetViaSize sets the via diameter, which defaults to the node size.
+func (pgo *PcbGeneticOperators) SetViaSize(viaSz float64) {
+	pgo.geometryParams.ViaSz = viaSz
+}
+
+func (pgo *PcbGeneticOperators) SetRoutingStyle(style RoutingStyle) {
+	pgo.geometryParams.Style = style
+}
+
+// SetVerifyIncremental enables checking that the incremental evaluation of every child gives the same
+// cost as evaluating it from scratch, panicking otherwise. It is meant for debugging and doubles
+// the evaluation time.
+func (pgo *PcbGeneticOperators) SetVerifyIncremental(verify bool) {
+	pgo.verifyIncremental = verify
+}
+
+func (pgo *PcbGeneticOperators) GeometryParams() GeometryParams {
+	return pgo.geometryParams
+}
+
+func (pgo *PcbGeneticOperators) EvaluationParams() EvaluationParams {
+	return pgo.evaluationParams
+}
+
+// Evaluate returns the fitness of the pcb, keeping its violations so that its children only check
+// the objects they change.
 func (pgo *PcbGeneticOperators) Evaluate(i *Pcb, c *genetic.GeneticContext) float64 {
-	cost := pgo.EvaluatePcbIntersections(i)
-	cost += pgo.EvaluatePcbEdgeLengths(i)
-	cost += pgo.EvaluateNonZeroPlaneEdges(i)
-	cost += pgo.EvaluateComponentsOutOfBounds(i)
-	cost = math.Pow(cost, pgo.fitnessExp)
-	fitness := -cost
-	return fitness
+	cost := pgo.evaluateCost(i)
+
+	if pgo.verifyIncremental && i.changes != nil {
+		full := NewPcb(i.Genome)
+		full.ComputeGeometryWithParams(pgo.geometryParams)
+
+		if expected := pgo.evaluateCost(full); math.Abs(cost-expected) > 1e-9*math.Max(1, math.Abs(expected)) || len(i.violations) != len(full.violations) {
+			panic(fmt.Sprintf("incremental evaluation found cost %v and %d violations, full evaluation %v and %d", cost, len(i.violations), expected, len(full.violations)))
+		}
+	}
+
+	// The parent is no longer needed, it can be freed along with its own ancestors
+	i.changes = nil
+
+	return pgo.fitness(cost)
+}
+
+// fitness turns the total cost of a pcb into its fitness.
+func (pgo *PcbGeneticOperators) fitness(cost float64) float64 {
+	return -math.Pow(cost, pgo.fitnessExp)
+}
+
+// evaluateCost returns the sum of the weighted cost terms of the pcb, storing the violations of
+// the design rule checks.
+func (pgo *PcbGeneticOperators) evaluateCost(i *Pcb) float64 {
+	i.violations = make([]Violation, 0)
+	cost := 0.0
+
+	for _, t := range pgo.costTerms {
+		if ct, ok := t.(*checkTerm); ok {
+			ct.check(i, func(v Violation) {
+				i.violations = append(i.violations, v)
+				cost += v.Cost
+			})
+		} else if w := t.Weight(); w != 0 {
+			cost += w * t.Evaluate(i)
+		}
+	}
+
+	return cost
 }
 
 func (pgo *PcbGeneticOperators) copyComponentNodesToChild(c *Genome, p *Genome, component int) {
@@ -67,12 +138,44 @@ func (pgo *PcbGeneticOperators) copyComponentNodesToChild(c *Genome, p *Genome,
 	}
 }
 
+// copyNetToChild gives the child the edges and waypoints of the net in the parent, appending the
+// waypoints after the ones the child already has.
+func (pgo *PcbGeneticOperators) copyNetToChild(child *Genome, parent *Genome, net int) {
+	remap := make(map[int]int, len(parent.Nets[net].Nodes))
+	child.Nets[net].Nodes = make([]int, 0, len(parent.Nets[net].Nodes))
+
+	for _, n := range parent.Nets[net].Nodes {
+		if parent.IsWaypoint(n) {
+			remap[n] = AddWaypoint(child, net, parent.Nodes[n].X, parent.Nodes[n].Y, &parent.Components[parent.Nodes[n].Component])
+		} else {
+			remap[n] = n
+			child.Nets[net].Nodes = append(child.Nets[net].Nodes, n)
+		}
+	}
+
+	for _, e := range parent.Edges {
+		if e.Net == net {
+			e.From, e.To = remap[e.From], remap[e.To]
+			child.Edges = append(child.Edges, e)
+		}
+	}
+}
+
 func (pgo *PcbGeneticOperators) CrossOver(i1 *Pcb, i2 *Pcb, c *genetic.GeneticContext) *Pcb {
 	child := i1.Genome.copy()
+	realComponents := i1.Genome.realComponents()
+
+	// Real components and nodes come first and are the same in both parents, waypoints are
+	// inherited along with the net they belong to
+	child.Nodes = child.Nodes[:i1.Genome.realNodes()]
+	child.Components = child.Components[:realComponents]
+	child.Edges = make([]Edge, 0, len(i1.Genome.Edges))
 
-	for i := 0; i < len(i1.Genome.Components); i++ {
+	for i := 0; i < realComponents; i++ {
 		v := c.RandomGenerator.Float64()
-		if v < 0.5 {
+		// Locked components are inherited from the first parent, so that they can't be
+		// moved even if the parents disagree
+		if v < 0.5 || i1.Genome.Components[i].Locked == LOCK_ALL {
 			pgo.copyComponentNodesToChild(child, i1.Genome, i)
 			child.Components[i] = *i1.Genome.Components[i].copy()
 		} else {
@@ -81,36 +184,34 @@ func (pgo *PcbGeneticOperators) CrossOver(i1 *Pcb, i2 *Pcb, c *genetic.GeneticCo
 		}
 	}
 
-	currentNet := i1.Genome.Edges[0].Net
-	v := c.RandomGenerator.Float64()
-
-	// Suppose both parent edges are sorted by net and omologous nets of both parents have same size
-	for i, e1 := range i1.Genome.Edges {
-		e2 := i2.Genome.Edges[i]
-
-		if e1.Net != currentNet {
-			v = c.RandomGenerator.Float64()
-			currentNet = e1.Net
-		}
-
-		if v < 0.5 {
-			child.Edges[i] = e1
+	for net := range child.Nets {
+		if c.RandomGenerator.Float64() < 0.5 {
+			pgo.copyNetToChild(child, i1.Genome, net)
 		} else {
-			child.Edges[i] = e2
+			pgo.copyNetToChild(child, i2.Genome, net)
 		}
-
 	}
 
-	return NewPcb(child)
+	res := NewPcb(child)
+	res.parent = i1
+	SortPcbEdges(res)
+
+	return res
 }
 
 func (pgo *PcbGeneticOperators) Mutate(i *Pcb, c *genetic.GeneticContext) {
-	if c.RandomGenerator.Float64() < pgo.mutateProb {
+	if pgo.mutationChooser != nil && c.RandomGenerator.Float64() < pgo.mutateProb {
 		mutation := pgo.mutationChooser.Pick()
 		mutation(i, c)
 	}
 }
 
+// Grow computes the geometry of the pcb, reusing the one of the objects unchanged since its parent.
 func (pgo *PcbGeneticOperators) Grow(i *Pcb, c *genetic.GeneticContext) {
-	i.ComputeGeometry(pgo.nodeSz, pgo.edgeSz)
+	if i.parent != nil && i.parent.violations != nil {
+		i.changes = diffPcb(i, i.parent, pgo.geometryParams.Planes)
+	}
+	i.parent = nil
+
+	i.ComputeGeometryWithParams(pgo.geometryParams)
 }
@@ -0,0 +1,228 @@
+package pcb
+
+// Children are mostly copies of their first parent with a few components moved or edges changed.
+// Grow matches their objects with the ones of that parent, so that the geometry of the unchanged
+// objects and the violations between them are taken from it instead of being computed again.
+
+// changes maps the objects of a pcb to the identical ones of the pcb it derives from. Objects that
+// are new, moved or otherwise different from any object of the parent are dirty and map to -1.
+type changes struct {
+	parent *Pcb
+	// nodes, edges and components hold the index in the parent of every object of the child
+	nodes      []int
+	edges      []int
+	components []int
+	// parentNodes, parentEdges and parentComponents are the reverse mappings
+	parentNodes      []int
+	parentEdges      []int
+	parentComponents []int
+}
+
+// waypointKey identifies a waypoint across individuals, whose waypoint indices differ.
+type waypointKey struct {
+	X, Y float64
+	Net  int
+}
+
+// edgeKey identifies an edge by its nodes in the parent.
+type edgeKey struct {
+	From, To   int
+	Net, Plane int
+	Bend       bool
+}
+
+func unmapped(n int) []int {
+	res := make([]int, n)
+	for i := range res {
+		res[i] = -1
+	}
+
+	return res
+}
+
+// samePlacement tells whether the two components have the same outline and pads.
+func samePlacement(c1, c2 *Component) bool {
+	return c1.Kind == c2.Kind && c1.CX == c2.CX && c1.CY == c2.CY && c1.Rotation == c2.Rotation && c1.Side == c2.Side &&
+		c1.X1 == c2.X1 && c1.Y1 == c2.Y1 && c1.X2 == c2.X2 && c1.Y2 == c2.Y2 && len(c1.Nodes) == len(c2.Nodes)
+}
+
+func samePlanes(planes1, planes2 []bool) bool {
+	if len(planes1) != len(planes2) {
+		return false
+	}
+
+	for i := range planes1 {
+		if planes1[i] != planes2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffPcb maps the objects of child to the identical ones of parent, which must have been evaluated.
+// Nodes must also be on the same planes and belong to the same net, edges must join mapped nodes.
+func diffPcb(child, parent *Pcb, planes int) *changes {
+	g, pg := child.Genome, parent.Genome
+	res := &changes{
+		parent:           parent,
+		nodes:            unmapped(len(g.Nodes)),
+		edges:            unmapped(len(g.Edges)),
+		components:       unmapped(len(g.Components)),
+		parentNodes:      unmapped(len(pg.Nodes)),
+		parentEdges:      unmapped(len(pg.Edges)),
+		parentComponents: unmapped(len(pg.Components)),
+	}
+
+	mapComponent := func(c, pc int) {
+		if res.components[c] < 0 && res.parentComponents[pc] < 0 && samePlacement(&g.Components[c], &pg.Components[pc]) {
+			res.components[c], res.parentComponents[pc] = pc, c
+		}
+	}
+
+	// Real components keep their indices in every individual
+	for i := 0; i < g.realComponents() && i < pg.realComponents(); i++ {
+		mapComponent(i, i)
+	}
+
+	nodeNets, parentNodeNets := g.nodeNets(), pg.nodeNets()
+	nodePlanes, parentNodePlanes := g.NodePlanes(planes), pg.NodePlanes(planes)
+
+	// Waypoints at the same place are ambiguous, they are left dirty
+	parentWaypoints := make(map[waypointKey]int)
+	for _, n := range pg.Waypoints() {
+		key := waypointKey{pg.Nodes[n].X, pg.Nodes[n].Y, parentNodeNets[n]}
+		if _, ok := parentWaypoints[key]; ok {
+			parentWaypoints[key] = -1
+		} else {
+			parentWaypoints[key] = n
+		}
+	}
+
+	waypointCount := make(map[waypointKey]int)
+	for _, n := range g.Waypoints() {
+		waypointCount[waypointKey{g.Nodes[n].X, g.Nodes[n].Y, nodeNets[n]}]++
+	}
+
+	for i, n := range g.Nodes {
+		p := -1
+
+		if g.IsWaypoint(i) {
+			key := waypointKey{n.X, n.Y, nodeNets[i]}
+			if pn, ok := parentWaypoints[key]; ok && waypointCount[key] == 1 {
+				p = pn
+			}
+		} else if i < len(pg.Nodes) && !pg.IsWaypoint(i) {
+			p = i
+		}
+
+		if p < 0 || pg.Nodes[p].X != n.X || pg.Nodes[p].Y != n.Y || parentNodeNets[p] != nodeNets[i] || !samePlanes(parentNodePlanes[p], nodePlanes[i]) {
+			continue
+		}
+
+		if g.IsWaypoint(i) {
+			mapComponent(n.Component, pg.Nodes[p].Component)
+		}
+
+		if res.components[n.Component] == pg.Nodes[p].Component {
+			res.nodes[i], res.parentNodes[p] = p, i
+		}
+	}
+
+	parentEdges := make(map[edgeKey]int, len(pg.Edges))
+	for i, e := range pg.Edges {
+		key := edgeKey{e.From, e.To, e.Net, e.Plane, e.Bend}
+		if _, ok := parentEdges[key]; ok {
+			parentEdges[key] = -1
+		} else {
+			parentEdges[key] = i
+		}
+	}
+
+	for i, e := range g.Edges {
+		from, to := res.nodes[e.From], res.nodes[e.To]
+		if from < 0 || to < 0 {
+			continue
+		}
+
+		if p, ok := parentEdges[edgeKey{from, to, e.Net, e.Plane, e.Bend}]; ok && p >= 0 && res.parentEdges[p] < 0 {
+			res.edges[i], res.parentEdges[p] = p, i
+		}
+	}
+
+	return res
+}
+
+// dirty tells whether the object of the child differs from every object of the parent. Keepouts
+// belong to the board and are never dirty, vias always are.
+func (ch *changes) dirty(o Object) bool {
+	return ch.mapObject(o, false) < 0
+}
+
+// cleanPair tells whether both objects are unchanged, so that the violation between them, if any,
+// is the one found in the parent.
+func (ch *changes) cleanPair(o1, o2 Object) bool {
+	return ch != nil && !ch.dirty(o1) && !ch.dirty(o2)
+}
+
+// mapObject returns the index in the parent of the object of the child, or the index in the child
+// of the object of the parent if reverse, -1 if there is none.
+func (ch *changes) mapObject(o Object, reverse bool) int {
+	var mapping, reverseMapping []int
+
+	switch o.Kind {
+	case NODE_OBJECT:
+		mapping, reverseMapping = ch.nodes, ch.parentNodes
+	case EDGE_OBJECT:
+		mapping, reverseMapping = ch.edges, ch.parentEdges
+	case COMPONENT_OBJECT:
+		mapping, reverseMapping = ch.components, ch.parentComponents
+	case KEEPOUT_OBJECT:
+		return o.Index
+	default:
+		return -1
+	}
+
+	if reverse {
+		return reverseMapping[o.Index]
+	}
+
+	return mapping[o.Index]
+}
+
+// replay reports, renumbered for the child, the violations of the parent of the given kinds whose
+// objects are all unchanged.
+func (ch *changes) replay(report func(Violation), kinds ...ViolationKind) {
+	if ch == nil {
+		return
+	}
+
+	for _, v := range ch.parent.violations {
+		if !hasKind(kinds, v.Kind) {
+			continue
+		}
+
+		objects := make([]Object, len(v.Objects))
+		clean := true
+
+		for i, o := range v.Objects {
+			objects[i] = Object{o.Kind, ch.mapObject(o, true)}
+			clean = clean && objects[i].Index >= 0
+		}
+
+		if clean {
+			v.Objects = objects
+			report(v)
+		}
+	}
+}
+
+func hasKind(kinds []ViolationKind, kind ViolationKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
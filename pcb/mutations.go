@@ -2,6 +2,7 @@ package pcb
 
 import (
 	"genetic_pcb/genetic"
+	"genetic_pcb/geo"
 
 	"github.com/mroth/weightedrand/v2"
 
@@ -13,15 +14,24 @@ type mutation = func(i *Pcb, c *genetic.GeneticContext)
 type mutationChooser = weightedrand.Chooser[mutation, int]
 
 type MutationParams struct {
-	GlobalMutationWeight                  int
-	TranslateComponentGroupMutationWeight int
-	RegenerateNetMutationWeight           int
-	RotateComponentMutationWeight         int
-	RerouteEdgeMutationWeight             int
-	ChangePlaneMutationWeight             int
-	EdgeBreakerComponent                  *Component
+	GlobalMutationWeight                  int `json:"globalMutationWeight"`
+	TranslateComponentGroupMutationWeight int `json:"translateComponentGroupMutationWeight"`
+	RegenerateNetMutationWeight           int `json:"regenerateNetMutationWeight"`
+	RotateComponentMutationWeight         int `json:"rotateComponentMutationWeight"`
+	RerouteEdgeMutationWeight             int `json:"rerouteEdgeMutationWeight"`
+	ChangePlaneMutationWeight             int `json:"changePlaneMutationWeight"`
+	FlipComponentMutationWeight           int `json:"flipComponentMutationWeight"`
+	BreakEdgeMutationWeight               int `json:"breakEdgeMutationWeight"`
+	JunctionMutationWeight                int `json:"junctionMutationWeight"`
+	MoveWaypointMutationWeight            int `json:"moveWaypointMutationWeight"`
+	RemoveWaypointMutationWeight          int `json:"removeWaypointMutationWeight"`
+	MergeWaypointsMutationWeight          int `json:"mergeWaypointsMutationWeight"`
+	FlipBendMutationWeight                int `json:"flipBendMutationWeight"`
+	// EdgeBreakerComponent is the shape of new waypoints, which have no outline if nil
+	EdgeBreakerComponent *Component `json:"-"`
 }
 
+// buildMutationChooser returns nil when all mutation weights are zero
 func (pgo *PcbGeneticOperators) buildMutationChooser() *mutationChooser {
 	chooser, _ := weightedrand.NewChooser(
 		weightedrand.NewChoice(pgo.globalMutation, pgo.mutationParams.GlobalMutationWeight),
@@ -30,32 +40,40 @@ func (pgo *PcbGeneticOperators) buildMutationChooser() *mutationChooser {
 		weightedrand.NewChoice(pgo.rotateComponent, pgo.mutationParams.RotateComponentMutationWeight),
 		weightedrand.NewChoice(pgo.rerouteEdge, pgo.mutationParams.RerouteEdgeMutationWeight),
 		weightedrand.NewChoice(pgo.changePlane, pgo.mutationParams.ChangePlaneMutationWeight),
+		weightedrand.NewChoice(pgo.flipComponent, pgo.mutationParams.FlipComponentMutationWeight),
+		weightedrand.NewChoice(pgo.breakEdge, pgo.mutationParams.BreakEdgeMutationWeight),
+		weightedrand.NewChoice(pgo.junction, pgo.mutationParams.JunctionMutationWeight),
+		weightedrand.NewChoice(pgo.moveWaypoint, pgo.mutationParams.MoveWaypointMutationWeight),
+		weightedrand.NewChoice(pgo.removeWaypoint, pgo.mutationParams.RemoveWaypointMutationWeight),
+		weightedrand.NewChoice(pgo.mergeWaypoints, pgo.mutationParams.MergeWaypointsMutationWeight),
+		weightedrand.NewChoice(pgo.flipBend, pgo.mutationParams.FlipBendMutationWeight),
 	)
 
 	return chooser
 }
 
 func (pgo *PcbGeneticOperators) globalMutation(i *Pcb, c *genetic.GeneticContext) {
-	for j := range i.Genome.Components {
+	for j := 0; j < i.Genome.realComponents(); j++ {
 		if c.RandomGenerator.Float64() < pgo.mutateSingleComponentProb {
 			component := &i.Genome.Components[j]
-			component.CX, component.CY = GetComponentRandomPositionInBoundaries(component, pgo.maxX, pgo.maxY, c.RandomGenerator)
-			component.Rotation = c.RandomGenerator.Float64() * 360
+			RandomizeComponentRotation(component, pgo.board, c.RandomGenerator)
+			RandomizeComponentPosition(component, pgo.board, c.RandomGenerator)
 			PlaceComponentNodes(i.Genome.Nodes, component)
 		}
 	}
 }
 
 func (pgo *PcbGeneticOperators) translateComponentGroup(i *Pcb, c *genetic.GeneticContext) {
-	DX, DY := (c.RandomGenerator.Float64()-0.5)*pgo.maxX*0.1, (c.RandomGenerator.Float64()-0.5)*pgo.maxY*0.1
+	DX, DY := (c.RandomGenerator.Float64()-0.5)*pgo.board.Width()*0.1, (c.RandomGenerator.Float64()-0.5)*pgo.board.Height()*0.1
 
-	for j := range i.Genome.Components {
+	// Waypoints are moved by moveWaypoint, not along with the components
+	for j := 0; j < i.Genome.realComponents(); j++ {
 		if c.RandomGenerator.Float64() < pgo.mutateSingleComponentProb {
 			component := &i.Genome.Components[j]
 			newX, newY := component.CX+DX, component.CY+DY
 
-			if newX >= 0 && newX < pgo.maxX && newY >= 0 && newY < pgo.maxY {
-				component.CX, component.CY = component.CX+DX, component.CY+DY
+			if pgo.board.ContainsPoint(newX, newY) {
+				MoveComponent(component, newX, newY, pgo.board)
 				PlaceComponentNodes(i.Genome.Nodes, component)
 			}
 
@@ -65,15 +83,21 @@ func (pgo *PcbGeneticOperators) translateComponentGroup(i *Pcb, c *genetic.Genet
 }
 
 func (pgo *PcbGeneticOperators) rotateComponent(i *Pcb, c *genetic.GeneticContext) {
-	for j := range i.Genome.Components {
+	for j := 0; j < i.Genome.realComponents(); j++ {
 		if c.RandomGenerator.Float64() < pgo.mutateSingleComponentProb {
 			component := &i.Genome.Components[j]
-			component.Rotation = c.RandomGenerator.Float64() * 360
+			RandomizeComponentRotation(component, pgo.board, c.RandomGenerator)
 			PlaceComponentNodes(i.Genome.Nodes, component)
 		}
 	}
 }
 
+func (pgo *PcbGeneticOperators) flipComponent(i *Pcb, c *genetic.GeneticContext) {
+	component := &i.Genome.Components[c.RandomGenerator.Intn(i.Genome.realComponents())]
+	FlipComponent(component, pgo.board)
+	PlaceComponentNodes(i.Genome.Nodes, component)
+}
+
 func clip(x, min, max float64) float64 {
 	if x < min {
 		return min
@@ -89,112 +113,244 @@ func clip(x, min, max float64) float64 {
 func (pgo *PcbGeneticOperators) localMutation(i *Pcb, c *genetic.GeneticContext) {
 	node := c.RandomGenerator.Intn(len(i.Genome.Nodes))
 
+	if i.Genome.Components[i.Genome.Nodes[node].Component].IsPositionLocked() {
+		return
+	}
+
 	dx, dy := (c.RandomGenerator.Float64()*pgo.localMutationMaxDelta)-pgo.localMutationMaxDelta/2, (c.RandomGenerator.Float64()*pgo.localMutationMaxDelta)-pgo.localMutationMaxDelta/2
 	x, y := i.Genome.Nodes[node].X, i.Genome.Nodes[node].Y
 
 	// fmt.Printf("%v, %v, %v\n", node, dx, dy)
 
-	i.Genome.Nodes[node] = Node{X: clip(x+dx, 0, pgo.maxX), Y: clip(y+dy, 0, pgo.maxY)}
+	bounds := pgo.board.Bounds()
+
+	i.Genome.Nodes[node] = Node{X: clip(x+dx, bounds.Min(0), bounds.Max(0)), Y: clip(y+dy, bounds.Min(1), bounds.Max(1)), Component: i.Genome.Nodes[node].Component}
 
 }
 
 func (pgo *PcbGeneticOperators) netMutation(i *Pcb, c *genetic.GeneticContext) {
 	netI := c.RandomGenerator.Intn(len(i.Genome.Nets))
+
+	// Zones connect their net, regenerating it only joins the pads their fill leaves apart
+	if pgo.board.HasZone(netI) {
+		RemoveNetEdges(i, netI)
+		i.connectZoneIslands(netI, pgo.geometryParams, c.RandomGenerator)
+		return
+	}
+
 	GenerateNet(i, netI, c.RandomGenerator)
 }
 
-func (pgo *PcbGeneticOperators) rerouteEdge(i *Pcb, c *genetic.GeneticContext) {
-	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
-	edge := i.Genome.Edges[edgeIndex]
-	netIndex := edge.Net
-	net := &i.Genome.Nets[netIndex]
+// splitNet returns the nodes of the two trees the net of the edge is split into when the edge is removed.
+func splitNet(g *Genome, edgeIndex int) ([]int, []int) {
+	netIndex := g.Edges[edgeIndex].Net
+	net := &g.Nets[netIndex]
 
-	g := simple.NewUndirectedGraph()
+	graph := simple.NewUndirectedGraph()
 
-	idToNodes := make(map[int64]int, len(i.Genome.Nodes))
-	nodesToId := make(map[int]int64, len(i.Genome.Nodes))
+	idToNodes := make(map[int64]int, len(net.Nodes))
+	nodesToId := make(map[int]int64, len(net.Nodes))
 
 	for _, node := range net.Nodes {
-		n := g.NewNode()
+		n := graph.NewNode()
 		idToNodes[n.ID()] = node
 		nodesToId[node] = n.ID()
-		g.AddNode(n)
+		graph.AddNode(n)
 	}
 
-	for i, edge := range i.Genome.Edges {
+	for i, edge := range g.Edges {
 		if i != edgeIndex && edge.Net == netIndex {
-			g.SetEdge(g.NewEdge(g.Node(nodesToId[edge.From]), g.Node(nodesToId[edge.To])))
+			graph.SetEdge(graph.NewEdge(graph.Node(nodesToId[edge.From]), graph.Node(nodesToId[edge.To])))
 		}
 	}
 
-	ccs := topo.ConnectedComponents(g)
+	ccs := topo.ConnectedComponents(graph)
 
 	if len(ccs) > 2 {
 		panic("Removing an edge resulted in more than 2 connected components, which is impossible for proper nets")
 	}
 
-	// fmt.Printf("Removing edge %d, %d from net %d\n", edge.From, edge.To, netIndex)
-	// // Stampa delle componenti fortemente connesse trovate
-	// for i, scc := range ccs {
-	// 	if i > 1 {
-
-	// 	}
-	// 	fmt.Printf("Componente fortemente connessa %d: ", i)
-	// 	for _, n := range scc {
-	// 		fmt.Printf("%v ", idToNodes[n.ID()])
-	// 	}
-	// 	fmt.Println()
-	// }
+	res := [2][]int{}
 
-	n1 := idToNodes[ccs[0][c.RandomGenerator.Intn(len(ccs[0]))].ID()]
-	n2 := idToNodes[ccs[1][c.RandomGenerator.Intn(len(ccs[1]))].ID()]
+	for j, cc := range ccs {
+		for _, n := range cc {
+			res[j] = append(res[j], idToNodes[n.ID()])
+		}
+	}
 
-	// fmt.Printf("Chosen nodes %d %d\n", n1, n2)
+	return res[0], res[1]
+}
 
-	i.Genome.Edges = append(
-		i.Genome.Edges[:edgeIndex],
-		i.Genome.Edges[edgeIndex+1:]...,
+func removeEdge(g *Genome, edgeIndex int) {
+	g.Edges = append(
+		g.Edges[:edgeIndex],
+		g.Edges[edgeIndex+1:]...,
 	)
+}
+
+func (pgo *PcbGeneticOperators) rerouteEdge(i *Pcb, c *genetic.GeneticContext) {
+	if len(i.Genome.Edges) == 0 {
+		return
+	}
+
+	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
+	edge := i.Genome.Edges[edgeIndex]
+
+	// The edges of zone nets may not span them, so removing one can split them in more than two parts
+	if pgo.board.HasZone(edge.Net) {
+		return
+	}
+
+	cc1, cc2 := splitNet(i.Genome, edgeIndex)
 
-	i.Genome.Edges = append(i.Genome.Edges, Edge{From: n1, To: n2, Net: netIndex, Plane: edge.Plane})
+	n1 := cc1[c.RandomGenerator.Intn(len(cc1))]
+	n2 := cc2[c.RandomGenerator.Intn(len(cc2))]
+
+	removeEdge(i.Genome, edgeIndex)
+	i.Genome.Edges = append(i.Genome.Edges, Edge{From: n1, To: n2, Net: edge.Net, Plane: edge.Plane})
 
 	SortPcbEdges(i)
 }
 
+// flipBend makes a random edge take the other path allowed by the routing style.
+func (pgo *PcbGeneticOperators) flipBend(i *Pcb, c *genetic.GeneticContext) {
+	if len(i.Genome.Edges) == 0 {
+		return
+	}
+
+	edge := &i.Genome.Edges[c.RandomGenerator.Intn(len(i.Genome.Edges))]
+	edge.Bend = !edge.Bend
+}
+
 func (pgo *PcbGeneticOperators) changePlane(i *Pcb, c *genetic.GeneticContext) {
+	if len(i.Genome.Edges) == 0 {
+		return
+	}
+
 	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
 	edge := &i.Genome.Edges[edgeIndex]
 
-	if edge.Plane == 0 {
-		edge.Plane = 1
-	} else {
-		edge.Plane = 0
+	planes := make([]int, 0)
+
+	for _, p := range pgo.board.AllowedPlanes(edge.Net) {
+		if p != edge.Plane {
+			planes = append(planes, p)
+		}
+	}
+
+	if len(planes) > 0 {
+		edge.Plane = planes[c.RandomGenerator.Intn(len(planes))]
+	}
+}
+
+// randomPointNear returns a point at most localMutationMaxDelta/2 away from x, y along each axis, within the board bounds.
+func (pgo *PcbGeneticOperators) randomPointNear(x, y float64, c *genetic.GeneticContext) (float64, float64) {
+	dx, dy := (c.RandomGenerator.Float64()*pgo.localMutationMaxDelta)-pgo.localMutationMaxDelta/2, (c.RandomGenerator.Float64()*pgo.localMutationMaxDelta)-pgo.localMutationMaxDelta/2
+	bounds := pgo.board.Bounds()
+
+	return clip(x+dx, bounds.Min(0), bounds.Max(0)), clip(y+dy, bounds.Min(1), bounds.Max(1))
+}
+
+// breakEdge splits a random edge at a new waypoint placed near its middle.
+func (pgo *PcbGeneticOperators) breakEdge(i *Pcb, c *genetic.GeneticContext) {
+	if len(i.Genome.Edges) == 0 {
+		return
 	}
+
+	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
+	from, to := i.Genome.Nodes[i.Genome.Edges[edgeIndex].From], i.Genome.Nodes[i.Genome.Edges[edgeIndex].To]
+	x, y := pgo.randomPointNear((from.X+to.X)/2, (from.Y+to.Y)/2, c)
+
+	splitEdge(i.Genome, edgeIndex, x, y, pgo.mutationParams.EdgeBreakerComponent)
+	SortPcbEdges(i)
 }
 
-// func (pgo *PcbGeneticOperators) breakEdge(i *Pcb, c *genetic.GeneticContext) {
-// 	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
-// 	edge := &i.Genome.Edges[edgeIndex]
+// junction removes a random edge and reconnects the two halves of its net with a T-junction: a node
+// of one half is joined to a new waypoint splitting an edge of the other half where it is closest.
+func (pgo *PcbGeneticOperators) junction(i *Pcb, c *genetic.GeneticContext) {
+	if len(i.Genome.Edges) == 0 {
+		return
+	}
+
+	edgeIndex := c.RandomGenerator.Intn(len(i.Genome.Edges))
+	edge := i.Genome.Edges[edgeIndex]
+
+	// The edges of zone nets may not span them, so removing one can split them in more than two parts
+	if pgo.board.HasZone(edge.Net) {
+		return
+	}
+
+	cc1, cc2 := splitNet(i.Genome, edgeIndex)
+
+	removeEdge(i.Genome, edgeIndex)
+
+	// The edges of the half that is split
+	targets := make([]int, 0)
+	inCC2 := make(map[int]bool, len(cc2))
+
+	for _, n := range cc2 {
+		inCC2[n] = true
+	}
+
+	for j, e := range i.Genome.Edges {
+		if e.Net == edge.Net && inCC2[e.From] {
+			targets = append(targets, j)
+		}
+	}
+
+	n1 := cc1[c.RandomGenerator.Intn(len(cc1))]
+
+	if len(targets) == 0 {
+		// The other half is a single node, there is no edge to branch from
+		i.Genome.Edges = append(i.Genome.Edges, Edge{From: n1, To: cc2[0], Net: edge.Net, Plane: edge.Plane})
+	} else {
+		target := targets[c.RandomGenerator.Intn(len(targets))]
+		from, to := i.Genome.Nodes[i.Genome.Edges[target].From], i.Genome.Nodes[i.Genome.Edges[target].To]
+		x, y := geo.ClosestPointOnSegment(i.Genome.Nodes[n1].X, i.Genome.Nodes[n1].Y, from.X, from.Y, to.X, to.Y)
+
+		waypoint := splitEdge(i.Genome, target, x, y, pgo.mutationParams.EdgeBreakerComponent)
+		i.Genome.Edges = append(i.Genome.Edges, Edge{From: n1, To: waypoint, Net: edge.Net, Plane: edge.Plane})
+	}
+
+	SortPcbEdges(i)
+}
 
-// 	component := pgo.mutationParams.EdgeBreakerComponent.copy()
+func (pgo *PcbGeneticOperators) moveWaypoint(i *Pcb, c *genetic.GeneticContext) {
+	waypoints := i.Genome.Waypoints()
+	if len(waypoints) == 0 {
+		return
+	}
 
-// 	component.CX = c.RandomGenerator.Float64() * pgo.maxX
-// 	component.CY = c.RandomGenerator.Float64() * pgo.maxY
+	node := waypoints[c.RandomGenerator.Intn(len(waypoints))]
+	x, y := pgo.randomPointNear(i.Genome.Nodes[node].X, i.Genome.Nodes[node].Y, c)
+	MoveWaypoint(i.Genome, node, x, y)
+}
 
-// 	i.Genome.Components = append(i.Genome.Components, *component)
+func (pgo *PcbGeneticOperators) removeWaypoint(i *Pcb, c *genetic.GeneticContext) {
+	waypoints := i.Genome.Waypoints()
+	if len(waypoints) == 0 {
+		return
+	}
 
-// 	i.Genome.Nodes = append(i.Genome.Nodes, )
+	RemoveWaypoint(i.Genome, waypoints[c.RandomGenerator.Intn(len(waypoints))])
+	SortPcbEdges(i)
+}
 
-// 	componentIndex = len(i.Genome.Components) - 1
+// mergeWaypoints contracts a random edge between two waypoints.
+func (pgo *PcbGeneticOperators) mergeWaypoints(i *Pcb, c *genetic.GeneticContext) {
+	candidates := make([]Edge, 0)
 
-// 	edgesToAdd = []Edge {
-// 		{From: edge.From, To: }
-// 	}
+	for _, e := range i.Genome.Edges {
+		if i.Genome.IsWaypoint(e.From) && i.Genome.IsWaypoint(e.To) {
+			candidates = append(candidates, e)
+		}
+	}
 
-// 	i.Genome.Edges = append(
-// 		i.Genome.Edges[:edgeIndex],
-// 		i.Genome.Edges[edgeIndex+1:]...,
-// 	)
+	if len(candidates) == 0 {
+		return
+	}
 
-// 	SortPcbEdges(i)
-// }
+	e := candidates[c.RandomGenerator.Intn(len(candidates))]
+	MergeWaypoints(i.Genome, e.From, e.To)
+	SortPcbEdges(i)
+}
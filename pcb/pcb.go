@@ -36,16 +36,69 @@ type Edge struct {
 	To    int
 	Net   int
 	Plane int
+	// Bend chooses the path of the edge among the two allowed by non straight routing styles
+	Bend bool
 }
 
 type Net struct {
 	Nodes []int
+	// Class is the index of the net class in Genome.NetClasses, if there are any
+	Class int
 }
 
+// NetClass overrides the global edge size, via size and minimum distance for the nets assigned to it.
+type NetClass struct {
+	Name      string
+	Width     float64
+	Clearance float64
+	ViaSz     float64
+}
+
+type PadType int
+
+const (
+	// Present on every plane
+	THROUGH_HOLE_PAD PadType = iota
+	// Present only on the copper plane of its component side
+	SMD_PAD
+)
+
+type PadShape int
+
+const (
+	RECTANGLE_PAD PadShape = iota
+	CIRCLE_PAD
+	// Rectangle whose corners are rounded with the pad Radius
+	ROUNDED_RECTANGLE_PAD
+	// Rectangle whose short sides are half circles
+	OVAL_PAD
+)
+
+// Number of segments approximating a quarter of circle in pad outlines
+const PAD_ARC_SEGMENTS = 8
+
+type BoardSide int
+
+const (
+	TOP_SIDE BoardSide = iota
+	BOTTOM_SIDE
+)
+
+// Copper plane where SMD pads of top side components lie, bottom side ones lie on the last plane
+const TOP_PLANE = 0
+
 type ComponentNode struct {
-	Node int
-	DX   float64
-	DY   float64
+	Node  int
+	DX    float64
+	DY    float64
+	Type  PadType
+	Shape PadShape
+	// W and H are the pad size along the component axes, a zero W means a square as large as the node size.
+	// Circles have a diameter of W.
+	W float64
+	H float64
+	// Radius is the corner radius of ROUNDED_RECTANGLE_PAD pads
+	Radius float64
 }
 
 type Component struct {
@@ -58,6 +111,15 @@ type Component struct {
 	CY       float64
 	Rotation float64
 	Kind     ComponentKind
+	Locked   LockKind
+	// Anchor keeps the component on a board side, free to slide along it
+	Anchor BoardEdge
+	// Rotations are the allowed rotations in degrees, DefaultRotations if empty
+	Rotations []float64
+	// ContinuousRotation allows any rotation in [0, 360), ignoring Rotations
+	ContinuousRotation bool
+	// Side is the board side the component is mounted on, bottom side components have mirrored pad offsets
+	Side BoardSide
 }
 
 func (c *Component) copy() *Component {
@@ -73,25 +135,138 @@ func (c *Component) copy() *Component {
 		CX:       c.CX,
 		CY:       c.CY,
 		Rotation: c.Rotation,
+		Kind:     c.Kind,
+		Locked:   c.Locked,
+		Anchor:   c.Anchor,
+		// Allowed rotations are never modified, so they can be shared
+		Rotations:          c.Rotations,
+		ContinuousRotation: c.ContinuousRotation,
+		Side:               c.Side,
 	}
 }
 
+// Plane returns the copper plane of the side the component is mounted on, in a board with the given number of planes.
+func (c *Component) Plane(planes int) int {
+	if c.Side == BOTTOM_SIDE {
+		return planes - 1
+	}
+
+	return TOP_PLANE
+}
+
+// ComponentNode returns the pad of the node in its component, or nil if the node has no component.
+func (g *Genome) ComponentNode(node int) *ComponentNode {
+	if g.Nodes[node].Component >= len(g.Components) {
+		return nil
+	}
+
+	c := &g.Components[g.Nodes[node].Component]
+
+	for i := range c.Nodes {
+		if c.Nodes[i].Node == node {
+			return &c.Nodes[i]
+		}
+	}
+
+	return nil
+}
+
+// PadPlane returns the plane of an SMD node, or false if the node is a through-hole pad.
+func (g *Genome) PadPlane(node, planes int) (int, bool) {
+	cn := g.ComponentNode(node)
+	if cn == nil {
+		return 0, false
+	}
+
+	return g.Components[g.Nodes[node].Component].Plane(planes), cn.Type == SMD_PAD
+}
+
+// NodePlanes returns, for every node and plane, whether the node has copper on the plane: through-hole
+// pads are on every plane, SMD pads on the plane of their side and waypoints on the planes of their edges.
+func (g *Genome) NodePlanes(planes int) [][]bool {
+	res := make([][]bool, len(g.Nodes))
+
+	for i := range g.Nodes {
+		res[i] = make([]bool, planes)
+
+		if g.IsWaypoint(i) {
+			continue
+		}
+
+		if plane, smd := g.PadPlane(i, planes); smd {
+			res[i][plane] = true
+			continue
+		}
+
+		for p := range res[i] {
+			res[i][p] = true
+		}
+	}
+
+	for _, e := range g.Edges {
+		for _, n := range []int{e.From, e.To} {
+			if g.IsWaypoint(n) && e.Plane < planes {
+				res[n][e.Plane] = true
+			}
+		}
+	}
+
+	return res
+}
+
+// sharePlane returns whether two nodes have copper on a common plane.
+func sharePlane(planes1, planes2 []bool) bool {
+	for p := range planes1 {
+		if planes1[p] && planes2[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Genome struct {
 	Nodes      []Node
 	Edges      []Edge
 	Nets       []Net
 	Components []Component
+	// NetClasses are never modified, so they are shared among copies
+	NetClasses []NetClass
 }
 
 type Geometry struct {
 	Nodes      []*geom.Polygon
 	Edges      []*geom.Polygon
 	Components []*geom.Polygon
+	Vias       []*geom.Polygon
+	// ViaSites holds the via polygons come from, with the same indices
+	ViaSites []Via
+	// EdgePaths are the center lines of the edges
+	EdgePaths [][]geom.Coord
+	// ZoneFills are the trapezoids filling each zone of the board
+	ZoneFills [][]*geom.Polygon
+}
+
+type GeometryParams struct {
+	NodeSz float64
+	EdgeSz float64
+	ViaSz  float64
+	// Planes is the number of copper planes, bottom side SMD pads lie on the last one
+	Planes int
+	Style  RoutingStyle
+	// Board is needed to fill its zones, which are left empty if nil
+	Board *Board
 }
 
 type Pcb struct {
 	Genome   *Genome
 	Geometry *Geometry
+	// parent is the individual the pcb was derived from, until Grow compares them
+	parent *Pcb
+	// changes maps the objects of the pcb to the ones of its parent, from Grow to Evaluate
+	changes *changes
+	// violations are the ones found by Evaluate, for the children of the pcb
+	violations []Violation
 }
 
 func (p *Pcb) String() string {
@@ -106,7 +281,11 @@ func (g *Genome) copy() *Genome {
 	copy(edges, g.Edges)
 
 	nets := make([]Net, len(g.Nets))
-	copy(nets, g.Nets)
+
+	// Waypoints are added to and removed from nets, so their nodes can't be shared
+	for i := range nets {
+		nets[i] = *g.Nets[i].copy()
+	}
 
 	components := make([]Component, len(g.Components))
 
@@ -119,6 +298,7 @@ func (g *Genome) copy() *Genome {
 		Edges:      edges,
 		Nets:       nets,
 		Components: components,
+		NetClasses: g.NetClasses,
 	}
 }
 
@@ -128,6 +308,7 @@ func (n *Net) copy() *Net {
 
 	return &Net{
 		Nodes: nodes,
+		Class: n.Class,
 	}
 }
 
@@ -136,6 +317,49 @@ func nodeToPoly(x, y, nodeSz float64) *geom.Polygon {
 	return geom.NewPolygonFlat(geom.XY, []float64{x - halfSz, y - halfSz, x + halfSz, y - halfSz, x + halfSz, y + halfSz, x - halfSz, y + halfSz, x - halfSz, y - halfSz}, []int{10})
 }
 
+// padToPoly returns the outline of the pad centered in x, y and rotated with its component. Curved
+// outlines are approximated with PAD_ARC_SEGMENTS segments per quarter of circle.
+func padToPoly(x, y float64, pad *ComponentNode, rotation float64) *geom.Polygon {
+	w, h, r := pad.W, pad.H, 0.0
+
+	switch pad.Shape {
+	case CIRCLE_PAD:
+		h = w
+		r = w / 2
+	case ROUNDED_RECTANGLE_PAD:
+		r = math.Min(pad.Radius, math.Min(w, h)/2)
+	case OVAL_PAD:
+		r = math.Min(w, h) / 2
+	}
+
+	if r <= 0 {
+		hw, hh := w/2, h/2
+		x1, y1 := geo.RotatePoint(x-hw, y-hh, x, y, rotation)
+		x2, y2 := geo.RotatePoint(x+hw, y-hh, x, y, rotation)
+		x3, y3 := geo.RotatePoint(x+hw, y+hh, x, y, rotation)
+		x4, y4 := geo.RotatePoint(x-hw, y+hh, x, y, rotation)
+
+		return geom.NewPolygonFlat(geom.XY, []float64{x1, y1, x2, y2, x3, y3, x4, y4, x1, y1}, []int{10})
+	}
+
+	// Centers of the corner arcs, counterclockwise from the bottom right one
+	cx, cy := w/2-r, h/2-r
+	corners := [][2]float64{{cx, -cy}, {cx, cy}, {-cx, cy}, {-cx, -cy}}
+	flatCoords := make([]float64, 0, 2*4*(PAD_ARC_SEGMENTS+1)+2)
+
+	for i, c := range corners {
+		for j := 0; j <= PAD_ARC_SEGMENTS; j++ {
+			angle := (float64(i) - 1 + float64(j)/PAD_ARC_SEGMENTS) * math.Pi / 2
+			px, py := geo.RotatePoint(x+c[0]+r*math.Cos(angle), y+c[1]+r*math.Sin(angle), x, y, rotation)
+			flatCoords = append(flatCoords, px, py)
+		}
+	}
+
+	flatCoords = append(flatCoords, flatCoords[0], flatCoords[1])
+
+	return geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+}
+
 func componentToPoly(c *Component) *geom.Polygon {
 	finalX1 := c.CX + c.X1
 	finalY1 := c.CY + c.Y1
@@ -190,34 +414,124 @@ func NewPcb(genome *Genome) *Pcb {
 	return &res
 }
 
+// ComputeGeometry computes the geometry of a two planes pcb, using vias as large as nodes.
 func (pcb *Pcb) ComputeGeometry(nodeSz, edgeSz float64) {
+	pcb.ComputeGeometryWithParams(GeometryParams{NodeSz: nodeSz, EdgeSz: edgeSz, ViaSz: nodeSz, Planes: DEFAULT_PLANES})
+}
+
+// ComputeGeometryWithParams computes the polygons of every object, taking the ones of the objects
+// Grow found unchanged from the parent.
+func (pcb *Pcb) ComputeGeometryWithParams(params GeometryParams) {
 	nodes := make([]*geom.Polygon, len(pcb.Genome.Nodes))
 	edges := make([]*geom.Polygon, len(pcb.Genome.Edges))
+	edgePaths := make([][]geom.Coord, len(pcb.Genome.Edges))
 	components := make([]*geom.Polygon, len(pcb.Genome.Components))
 
+	// Unchanged objects have the geometry they have in the parent
+	var parent *Geometry
+	if pcb.changes != nil {
+		parent = pcb.changes.parent.Geometry
+	}
+
 	for i, coords := range pcb.Genome.Nodes {
+		if parent != nil && pcb.changes.nodes[i] >= 0 {
+			nodes[i] = parent.Nodes[pcb.changes.nodes[i]]
+			continue
+		}
+
 		x, y := coords.X, coords.Y
+		nodeSz := params.NodeSz
+
+		// Waypoints are trace bends, as large as the traces of their net
+		if pcb.Genome.IsWaypoint(i) {
+			nodeSz = pcb.Genome.EdgeWidth(pcb.Genome.nodeNet(i), params.EdgeSz)
+		} else if pad := pcb.Genome.ComponentNode(i); pad != nil && pad.W > 0 {
+			nodes[i] = padToPoly(x, y, pad, pcb.Genome.Components[coords.Component].Rotation)
+			continue
+		}
+
 		nodePoly := nodeToPoly(x, y, nodeSz)
 		nodes[i] = nodePoly
 	}
 
 	for i, edge := range pcb.Genome.Edges {
-		edgePoly := edgeToPoly(edge.From, edge.To, pcb.Genome.Nodes, edgeSz)
-		edges[i] = edgePoly
+		if parent != nil && pcb.changes.edges[i] >= 0 {
+			edges[i], edgePaths[i] = parent.Edges[pcb.changes.edges[i]], parent.EdgePaths[pcb.changes.edges[i]]
+			continue
+		}
+
+		edgeSz := pcb.Genome.EdgeWidth(edge.Net, params.EdgeSz)
+		edgePaths[i] = EdgePath(pcb.Genome.Nodes[edge.From], pcb.Genome.Nodes[edge.To], edge.Bend, params.Style)
+
+		if params.Style == STRAIGHT_ROUTING {
+			edges[i] = edgeToPoly(edge.From, edge.To, pcb.Genome.Nodes, edgeSz)
+		} else {
+			edges[i] = geo.PolylineToPolygon(edgePaths[i], edgeSz)
+		}
 	}
 
 	for i, component := range pcb.Genome.Components {
+		if parent != nil && pcb.changes.components[i] >= 0 {
+			components[i] = parent.Components[pcb.changes.components[i]]
+			continue
+		}
+
 		componentPoly := componentToPoly(&component)
 		components[i] = componentPoly
 	}
 
+	viaSites := pcb.Genome.ComputeVias(params.Planes)
+	vias := make([]*geom.Polygon, len(viaSites))
+
+	for i := range viaSites {
+		vias[i] = viaToPoly(&viaSites[i], pcb.Genome.ViaSize(viaSites[i].Net, params.ViaSz))
+	}
+
 	geometry := Geometry{
 		Nodes:      nodes,
 		Edges:      edges,
 		Components: components,
+		Vias:       vias,
+		ViaSites:   viaSites,
+		EdgePaths:  edgePaths,
 	}
 
 	pcb.Geometry = &geometry
+	pcb.Geometry.ZoneFills = pcb.fillZones(params)
+}
+
+// NetClass returns the class of the net, or nil if the genome has no net classes or net is -1.
+func (g *Genome) NetClass(net int) *NetClass {
+	if len(g.NetClasses) == 0 || net < 0 {
+		return nil
+	}
+
+	return &g.NetClasses[g.Nets[net].Class]
+}
+
+// EdgeWidth returns the width of the edges of the net, the one of its class or edgeSz.
+func (g *Genome) EdgeWidth(net int, edgeSz float64) float64 {
+	if class := g.NetClass(net); class != nil {
+		return class.Width
+	}
+
+	return edgeSz
+}
+
+// ViaSize returns the diameter of the vias of the net, the one of its class or viaSz.
+func (g *Genome) ViaSize(net int, viaSz float64) float64 {
+	if class := g.NetClass(net); class != nil {
+		return class.ViaSz
+	}
+
+	return viaSz
+}
+
+// IsWaypoint tells whether the node belongs to an edge breaker rather than to a real component.
+func (g *Genome) IsWaypoint(node int) bool {
+	c := g.Nodes[node].Component
+
+	return c < len(g.Components) && g.Components[c].Kind == EDGE_BREAKER_COMPONENT
 }
 
 func (g *Genome) AreAdjacent(edgeIndex1, edgeIndex2 int) bool {
@@ -233,30 +547,30 @@ func (g *Genome) IsNodeOnEdge(nodeIndex, edgeIndex int) bool {
 	return f == nodeIndex || t == nodeIndex
 }
 
-func reduceLuminosity(originalColor color.Color) color.Color {
-	// Get the RGBA values of the original color
-	originalR, originalG, originalB, originalA := originalColor.RGBA()
-
-	// Convert the uint32 RGBA values to uint8 values
-	r := uint8(originalR / 0x101)
-	g := uint8(originalG / 0x101)
-	b := uint8(originalB / 0x101)
-	a := uint8(originalA / 0x101)
-
-	// Calculate the new RGB values by reducing the luminosity by 50%
-	newR := uint8(math.Max(float64(r)/2, 0))
-	newG := uint8(math.Max(float64(g)/2, 0))
-	newB := uint8(math.Max(float64(b)/2, 0))
+func keepoutColor(k Keepout) color.Color {
+	if k.Kind == COMPONENT_KEEPOUT {
+		return color.RGBA{255, 255, 0, 255}
+	}
 
-	// Create the new color with the updated RGB values
-	return color.RGBA{newR, newG, newB, a}
+	return color.RGBA{255, 128, 0, 255}
 }
 
-func DrawPcbToImage(pcb *Pcb, imgPath string, imgW, imgH int, sx, sy float64, netColors []color.Color) {
+func DrawPcbToImage(pcb *Pcb, board *Board, imgPath string, imgW, imgH int, sx, sy float64, netColors []color.Color) {
 
 	img := image.NewRGBA(image.Rect(0, 0, imgW, imgH))
 	gc := draw2dimg.NewGraphicContext(img)
 
+	if board != nil {
+		gc.SetLineWidth(2)
+		gc.SetStrokeColor(color.RGBA{0, 160, 0, 255})
+		draw.StrokePolyRings(gc, board.Outline, sx, sy)
+
+		for _, k := range board.Keepouts {
+			gc.SetStrokeColor(keepoutColor(k))
+			draw.StrokePolyRings(gc, k.Area, sx, sy)
+		}
+	}
+
 	gc.SetLineWidth(0)
 
 	fontData := goregular.TTF
@@ -267,18 +581,29 @@ func DrawPcbToImage(pcb *Pcb, imgPath string, imgW, imgH int, sx, sy float64, ne
 
 	gc.SetFont(font)
 	gc.SetFontSize(50)
+	gc.SetLineWidth(1)
 
-	for i, edge := range pcb.Geometry.Edges {
-		cl := netColors[pcb.Genome.Edges[i].Net%len(netColors)]
+	// Zones are drawn under the edges, with the color of their layer
+	for i, fill := range pcb.Geometry.ZoneFills {
+		gc.SetFillColor(board.LayerColor(board.Zones[i].Plane))
 
-		if pcb.Genome.Edges[i].Plane == 0 {
-			gc.SetFillColor(cl)
-		} else {
-			gc.SetFillColor(reduceLuminosity(cl))
-			fmt.Println("HEHEHE")
+		for _, p := range fill {
+			draw.DrawPoly(gc, p, sx, sy, true)
 		}
+	}
 
+	// Edges are filled with the color of their layer and outlined with the color of their net
+	for i, edge := range pcb.Geometry.Edges {
+		gc.SetFillColor(board.LayerColor(pcb.Genome.Edges[i].Plane))
 		draw.DrawPoly(gc, edge, sx, sy, true)
+		gc.SetStrokeColor(netColors[pcb.Genome.Edges[i].Net%len(netColors)])
+		draw.DrawPoly(gc, edge, sx, sy, false)
+	}
+
+	gc.SetFillColor(color.RGBA{180, 180, 180, 255})
+
+	for _, via := range pcb.Geometry.Vias {
+		draw.DrawPoly(gc, via, sx, sy, true)
 	}
 
 	for i, node := range pcb.Geometry.Nodes {
@@ -294,9 +619,18 @@ func DrawPcbToImage(pcb *Pcb, imgPath string, imgW, imgH int, sx, sy float64, ne
 
 	gc.SetFillColor(color.RGBA{255, 255, 255, 0})
 	gc.SetLineWidth(1)
-	gc.SetStrokeColor(color.RGBA{255, 255, 255, 255})
 
-	for _, component := range pcb.Geometry.Components {
+	for i, component := range pcb.Geometry.Components {
+		if pcb.Genome.Components[i].Kind == EDGE_BREAKER_COMPONENT {
+			continue
+		}
+
+		if pcb.Genome.Components[i].Side == BOTTOM_SIDE {
+			gc.SetStrokeColor(color.RGBA{120, 120, 255, 255})
+		} else {
+			gc.SetStrokeColor(color.RGBA{255, 255, 255, 255})
+		}
+
 		draw.DrawPoly(gc, component, sx, sy, false)
 	}
 
@@ -304,9 +638,18 @@ func DrawPcbToImage(pcb *Pcb, imgPath string, imgW, imgH int, sx, sy float64, ne
 
 }
 
+// GetTotalPcbLength returns the length of all the edges, following their paths if the geometry has been computed.
 func GetTotalPcbLength(pcb *Pcb) float64 {
 	res := 0.0
 
+	if pcb.Geometry != nil && len(pcb.Geometry.EdgePaths) == len(pcb.Genome.Edges) {
+		for _, path := range pcb.Geometry.EdgePaths {
+			res += geo.PolylineLength(path)
+		}
+
+		return res
+	}
+
 	for _, edge := range pcb.Genome.Edges {
 		x1, y1 := pcb.Genome.Nodes[edge.From].X, pcb.Genome.Nodes[edge.From].Y
 		x2, y2 := pcb.Genome.Nodes[edge.To].X, pcb.Genome.Nodes[edge.To].Y
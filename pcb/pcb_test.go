@@ -1,8 +1,15 @@
 package pcb_test
 
 import (
+	"encoding/json"
+	"genetic_pcb/genetic"
+	"genetic_pcb/geo"
 	"genetic_pcb/pcb"
+	"math"
+	"math/rand"
 	"testing"
+
+	"github.com/twpayne/go-geom"
 )
 
 func runTest(t *testing.T, g pcb.Genome, expected float64) {
@@ -119,3 +126,1127 @@ func TestEvaluatePcb5(t *testing.T) {
 		-2.0,
 	)
 }
+
+func TestEvaluateKeepouts(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		ComponentKeepoutCost: 10,
+		RoutingKeepoutCost:   1,
+	})
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Keepouts = []pcb.Keepout{
+		{Kind: pcb.COMPONENT_KEEPOUT, Area: geom.NewPolygonFlat(geom.XY, []float64{0, 0, 20, 0, 20, 20, 0, 20, 0, 0}, []int{10})},
+		{Kind: pcb.ROUTING_KEEPOUT, Plane: 1, Area: geom.NewPolygonFlat(geom.XY, []float64{40, 0, 60, 0, 60, 100, 40, 100, 40, 0}, []int{10})},
+	}
+	pgo.SetBoard(board)
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10, Component: 0},
+			{X: 80, Y: 10, Component: 1},
+			{X: 80, Y: 80, Component: 2},
+			{X: 30, Y: 80, Component: 3},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0, Plane: 1},
+			{From: 2, To: 3, Net: 1, Plane: 0},
+		},
+		Components: []pcb.Component{
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 10, CY: 10},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 80, CY: 10},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 80, CY: 80},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 30, CY: 80},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	// One component in the component keepout, one plane 1 edge through the routing keepout
+	if res := pgo.EvaluateKeepouts(p); res != 11 {
+		t.Errorf("Expected 11, got %v", res)
+	}
+}
+
+func TestLockedComponents(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 1, 100, 100, 4, 2, 0, pcb.MutationParams{
+		GlobalMutationWeight:                  1,
+		TranslateComponentGroupMutationWeight: 1,
+		RotateComponentMutationWeight:         1,
+		FlipComponentMutationWeight:           1,
+	}, pcb.EvaluationParams{})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10, Component: 0},
+			{X: 50, Y: 50, Component: 1},
+			{X: 77, Y: 80, Component: 2},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1, 2}}},
+		Components: []pcb.Component{
+			{Nodes: []pcb.ComponentNode{{Node: 0}}, X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 10, CY: 10, Locked: pcb.LOCK_ALL},
+			{Nodes: []pcb.ComponentNode{{Node: 1}}, X1: -5, Y1: -2, X2: 5, Y2: 2, CX: 50, CY: 50, Anchor: pcb.TOP_EDGE},
+			{Nodes: []pcb.ComponentNode{{Node: 2, DX: -3}}, X1: -5, Y1: -2, X2: 8, Y2: 2, CX: 80, CY: 80, Locked: pcb.LOCK_POSITION},
+		},
+	})
+
+	c := genetic.NewGeneticContext()
+
+	for i := 0; i < 100; i++ {
+		p = pcb.ScrumblePcbOnBoard(p, pgo.Board())
+		pgo.Mutate(p, c)
+		p.ComputeGeometry(4, 2)
+
+		if locked := p.Genome.Components[0]; locked.CX != 10 || locked.CY != 10 || locked.Rotation != 0 {
+			t.Fatalf("Locked component moved to %v,%v rotated by %v", locked.CX, locked.CY, locked.Rotation)
+		}
+
+		if top := p.Geometry.Components[1].Bounds().Min(1); math.Abs(top) > 1e-9 {
+			t.Fatalf("Anchored component left the top edge, its top is at %v", top)
+		}
+
+		// Flipping would mirror the pads of the component whose position is locked
+		if connector := p.Genome.Components[2]; connector.Side != pcb.TOP_SIDE || connector.Nodes[0].DX != -3 || connector.X1 != -5 {
+			t.Fatalf("Position locked component was flipped: %+v", connector)
+		}
+	}
+}
+
+func TestAnchorToOutline(t *testing.T) {
+	// The left side of the board is slanted, the bottom right corner is notched
+	board := pcb.NewBoard(geom.NewPolygonFlat(geom.XY, []float64{0, 0, 100, 0, 100, 60, 50, 60, 50, 100, 40, 100, 0, 0}, []int{14}))
+
+	cases := []struct {
+		anchor pcb.BoardEdge
+		cx, cy float64
+		// Expected side of the component touching the board edge
+		side float64
+	}{
+		// The slanted edge is at 22 at the top of the component, at 14 at its bottom
+		{pcb.LEFT_EDGE, 60, 45, 22},
+		{pcb.BOTTOM_EDGE, 80, 20, 60},
+		{pcb.BOTTOM_EDGE, 45, 20, 60},
+		// Under the slanted edge, which is at 62.5 on the left of the component
+		{pcb.BOTTOM_EDGE, 30, 20, 62.5},
+		{pcb.RIGHT_EDGE, 42, 80, 50},
+	}
+
+	for _, tc := range cases {
+		c := pcb.Component{X1: -5, Y1: -10, X2: 5, Y2: 10, CX: tc.cx, CY: tc.cy, Anchor: tc.anchor}
+		pcb.AnchorToBoardEdge(&c, board)
+
+		side := map[pcb.BoardEdge]float64{pcb.LEFT_EDGE: c.CX - 5, pcb.RIGHT_EDGE: c.CX + 5, pcb.BOTTOM_EDGE: c.CY + 10}[tc.anchor]
+		if math.Abs(side-tc.side) > 1e-9 {
+			t.Errorf("Expected the component anchored to %v at %v, %v to touch %v, got %v", tc.anchor, tc.cx, tc.cy, tc.side, side)
+		}
+
+		outline := geom.NewPolygonFlat(geom.XY, []float64{c.CX - 5, c.CY - 10, c.CX + 5, c.CY - 10, c.CX + 5, c.CY + 10, c.CX - 5, c.CY + 10, c.CX - 5, c.CY - 10}, []int{10})
+		if !geo.IsContainedInPolygon(outline, board.Outline) {
+			t.Errorf("Expected the component anchored to %v at %v, %v to be inside the board, got %v, %v", tc.anchor, tc.cx, tc.cy, c.CX, c.CY)
+		}
+	}
+}
+
+func TestDiscreteRotations(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 1, 100, 100, 4, 2, 0, pcb.MutationParams{
+		GlobalMutationWeight:          1,
+		RotateComponentMutationWeight: 1,
+	}, pcb.EvaluationParams{})
+
+	templates := []pcb.Component{
+		{Nodes: []pcb.ComponentNode{{DX: -2}, {DX: 2}}, X1: -5, Y1: -5, X2: 5, Y2: 5, Rotations: []float64{0, 180}},
+		{Nodes: []pcb.ComponentNode{{DX: -2}, {DX: 2}}, X1: -5, Y1: -5, X2: 5, Y2: 5},
+	}
+	allowed := [][]float64{{0, 180}, pcb.DefaultRotations}
+
+	p := pcb.GeneratePcbFullOnBoard(templates, 10, 2, pgo.Board(), rand.New(rand.NewSource(1)))
+	c := genetic.NewGeneticContext()
+
+	for i := 0; i < 100; i++ {
+		pgo.Mutate(p, c)
+
+		for _, component := range p.Genome.Components {
+			rotations := allowed[1]
+			if len(component.Rotations) > 0 {
+				rotations = allowed[0]
+			}
+
+			found := false
+			for _, r := range rotations {
+				found = found || r == component.Rotation
+			}
+
+			if !found {
+				t.Fatalf("Rotation %v is not in %v", component.Rotation, rotations)
+			}
+		}
+	}
+}
+
+func TestFlipComponent(t *testing.T) {
+	board := pcb.NewRectangularBoard(100, 100)
+	c := pcb.Component{
+		Nodes: []pcb.ComponentNode{{Node: 0, DX: -3, DY: 1, Type: pcb.SMD_PAD}},
+		X1:    -5, Y1: -2, X2: 8, Y2: 2,
+	}
+
+	pcb.FlipComponent(&c, board)
+
+	if c.Side != pcb.BOTTOM_SIDE || c.Nodes[0].DX != 3 || c.Nodes[0].DY != 1 || c.X1 != -8 || c.X2 != 5 {
+		t.Errorf("Unexpected flipped component %+v", c)
+	}
+
+	if c.Plane(4) != 3 {
+		t.Errorf("Expected plane 3, got %v", c.Plane(4))
+	}
+
+	pcb.FlipComponent(&c, board)
+
+	if c.Side != pcb.TOP_SIDE || c.Nodes[0].DX != -3 || c.X1 != -5 || c.X2 != 8 {
+		t.Errorf("Flipping twice did not restore the component: %+v", c)
+	}
+}
+
+func TestComputeVias(t *testing.T) {
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10, Component: 0},
+			{X: 50, Y: 10, Component: 1},
+			{X: 90, Y: 10, Component: 2},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0, Plane: 0},
+			{From: 1, To: 2, Net: 0, Plane: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1, 2}}},
+		Components: []pcb.Component{
+			{Nodes: []pcb.ComponentNode{{Node: 0, Type: pcb.SMD_PAD}}, CX: 10, CY: 10},
+			{Nodes: []pcb.ComponentNode{{Node: 1, Type: pcb.SMD_PAD}}, CX: 50, CY: 10},
+			{Nodes: []pcb.ComponentNode{{Node: 2}}, CX: 90, CY: 10},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	// Node 0 is reached on its own plane and node 2 is through-hole, only node 1 switches plane
+	if len(p.Geometry.ViaSites) != 1 {
+		t.Fatalf("Expected 1 via, got %v", p.Geometry.ViaSites)
+	}
+
+	if v := p.Geometry.ViaSites[0]; v.Node != 1 || v.FromPlane != 0 || v.ToPlane != 1 {
+		t.Errorf("Unexpected via %+v", v)
+	}
+}
+
+func TestLayerAwarePadClearance(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		MinDist:                   1,
+	})
+
+	// An edge of net 1 passing over the pad of net 0
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 50, Y: 50, Component: 0},
+			{X: 10, Y: 50, Component: 1},
+			{X: 90, Y: 50, Component: 2},
+		},
+		Edges: []pcb.Edge{{From: 1, To: 2, Net: 1}},
+		Nets:  []pcb.Net{{Nodes: []int{0}}, {Nodes: []int{1, 2}}},
+		Components: []pcb.Component{
+			{Nodes: []pcb.ComponentNode{{Node: 0, Type: pcb.SMD_PAD}}, CX: 50, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 1}}, CX: 10, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 2}}, CX: 90, CY: 50},
+		},
+	})
+
+	cases := []struct {
+		padType pcb.PadType
+		side    pcb.BoardSide
+		plane   int
+		cost    float64
+	}{
+		{pcb.SMD_PAD, pcb.TOP_SIDE, 0, 1},
+		{pcb.SMD_PAD, pcb.TOP_SIDE, 1, 0},
+		{pcb.SMD_PAD, pcb.BOTTOM_SIDE, 0, 0},
+		{pcb.SMD_PAD, pcb.BOTTOM_SIDE, 1, 1},
+		{pcb.THROUGH_HOLE_PAD, pcb.TOP_SIDE, 1, 1},
+	}
+
+	for _, tc := range cases {
+		p.Genome.Components[0].Nodes[0].Type = tc.padType
+		p.Genome.Components[0].Side = tc.side
+		p.Genome.Edges[0].Plane = tc.plane
+		p.ComputeGeometry(4, 2)
+
+		if cost := pgo.EvaluatePcbIntersections(p); cost != tc.cost {
+			t.Errorf("Pad %v on side %v, edge on plane %v: expected cost %v, got %v", tc.padType, tc.side, tc.plane, tc.cost, cost)
+		}
+	}
+}
+
+func TestLayerStack(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 4, 2, 0, pcb.MutationParams{
+		ChangePlaneMutationWeight: 1,
+	}, pcb.EvaluationParams{ForbiddenLayerCost: 100})
+
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Layers = []pcb.Layer{
+		{Name: "Top", RoutingCost: 0},
+		{Name: "GND", RoutingCost: 1, AllowedNets: []int{1}},
+		{Name: "Inner", RoutingCost: 2},
+		{Name: "Bottom", RoutingCost: 3},
+	}
+	pgo.SetBoard(board)
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10},
+			{X: 50, Y: 10},
+			{X: 10, Y: 50},
+			{X: 50, Y: 50},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}},
+	})
+
+	c := genetic.NewGeneticContext()
+	used := make(map[int]bool)
+
+	for i := 0; i < 200; i++ {
+		pgo.Mutate(p, c)
+
+		if p.Genome.Edges[0].Plane == 1 {
+			t.Fatalf("Net 0 was moved to a layer reserved to net 1")
+		}
+
+		used[p.Genome.Edges[1].Plane] = true
+	}
+
+	if len(used) != 4 {
+		t.Errorf("Expected net 1 to visit all 4 layers, visited %v", used)
+	}
+
+	p.Genome.Edges[0].Plane, p.Genome.Edges[1].Plane = 3, 1
+	if res := pgo.EvaluateNonZeroPlaneEdges(p); res != 4 {
+		t.Errorf("Expected 4, got %v", res)
+	}
+
+	p.Genome.Edges[0].Plane = 1
+	if res := pgo.EvaluateNonZeroPlaneEdges(p); res != 101 {
+		t.Errorf("Expected 101, got %v", res)
+	}
+
+	// The term is weighted by the forbidden layer cost, the most expensive, and counts 1.01 edges
+	p.ComputeGeometry(4, 2)
+	for _, term := range pgo.CostTerms() {
+		if term.Name() != "layers" {
+			continue
+		}
+
+		if w, raw := term.Weight(), term.Evaluate(p); w != 100 || math.Abs(raw-1.01) > 1e-9 {
+			t.Errorf("Expected weight 100 and value 1.01, got %v and %v", w, raw)
+		}
+	}
+
+	if term := pgo.EvaluateDetailed(p).Term("layers"); math.Abs(term.Raw-1.01) > 1e-9 || math.Abs(term.Cost-101) > 1e-9 {
+		t.Errorf("Expected the breakdown to count 1.01 edges for 101, got %+v", term)
+	}
+}
+
+func TestNetClasses(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 1, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		MinDist:                   2,
+	})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10},
+			{X: 50, Y: 10},
+			{X: 10, Y: 15},
+			{X: 50, Y: 15},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}},
+	})
+
+	pgo.Grow(p, genetic.NewGeneticContext())
+	if res := pgo.EvaluatePcbIntersections(p); res != 0 {
+		t.Errorf("Expected no intersections without net classes, got %v", res)
+	}
+
+	p.Genome.NetClasses = []pcb.NetClass{
+		{Name: "default", Width: 2, Clearance: 2, ViaSz: 1},
+		{Name: "power", Width: 4, Clearance: 3, ViaSz: 1},
+	}
+	p.Genome.Nets[1].Class = 1
+
+	pgo.Grow(p, genetic.NewGeneticContext())
+	if b := p.Geometry.Edges[1].Bounds(); b.Max(1)-b.Min(1) != 4 {
+		t.Errorf("Expected the power edge to be 4 wide, got %v", b.Max(1)-b.Min(1))
+	}
+
+	// The gap between the edges is now 2 and the one between the power edge and the pads of net 0 is
+	// 2.5, both within the clearance of the power class
+	if res := pgo.EvaluatePcbIntersections(p); res != 3 {
+		t.Errorf("Expected the power class clearance to be violated, got %v", res)
+	}
+}
+
+// checkRouting fails unless every net is routed by a tree spanning its nodes and every node is
+// referenced by its component.
+func checkRouting(t *testing.T, g *pcb.Genome) {
+	t.Helper()
+
+	for n, node := range g.Nodes {
+		found := false
+		for _, cn := range g.Components[node.Component].Nodes {
+			found = found || cn.Node == n
+		}
+
+		if !found {
+			t.Fatalf("Node %d is not referenced by its component %d", n, node.Component)
+		}
+	}
+
+	for i, net := range g.Nets {
+		parent := make(map[int]int, len(net.Nodes))
+		for _, n := range net.Nodes {
+			parent[n] = n
+		}
+
+		var find func(n int) int
+		find = func(n int) int {
+			if parent[n] != n {
+				parent[n] = find(parent[n])
+			}
+			return parent[n]
+		}
+
+		edges := 0
+
+		for _, e := range g.Edges {
+			if e.Net != i {
+				continue
+			}
+
+			if _, ok := parent[e.From]; !ok {
+				t.Fatalf("Edge %v of net %d starts outside of it", e, i)
+			}
+
+			if _, ok := parent[e.To]; !ok {
+				t.Fatalf("Edge %v of net %d ends outside of it", e, i)
+			}
+
+			parent[find(e.From)] = find(e.To)
+			edges++
+		}
+
+		if edges != len(net.Nodes)-1 {
+			t.Fatalf("Net %d has %d nodes and %d edges", i, len(net.Nodes), edges)
+		}
+
+		for _, n := range net.Nodes {
+			if find(n) != find(net.Nodes[0]) {
+				t.Fatalf("Net %d is not connected", i)
+			}
+		}
+	}
+}
+
+func TestWaypoints(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0.5, 100, 100, 4, 2, 20, pcb.MutationParams{
+		TranslateComponentGroupMutationWeight: 1,
+		RegenerateNetMutationWeight:           1,
+		RerouteEdgeMutationWeight:             1,
+		BreakEdgeMutationWeight:               3,
+		JunctionMutationWeight:                3,
+		MoveWaypointMutationWeight:            1,
+		RemoveWaypointMutationWeight:          1,
+		MergeWaypointsMutationWeight:          1,
+	}, pcb.EvaluationParams{})
+
+	templates := []pcb.Component{
+		{Nodes: []pcb.ComponentNode{{DX: -2}, {DX: 2}}, X1: -5, Y1: -5, X2: 5, Y2: 5},
+		{Nodes: []pcb.ComponentNode{{DX: -3}, {DX: 0}, {DX: 3}}, X1: -5, Y1: -5, X2: 5, Y2: 5},
+	}
+
+	p1 := pcb.GeneratePcbFullOnBoard(templates, 8, 3, pgo.Board(), rand.New(rand.NewSource(1)))
+	p2 := pcb.ScrumblePcbOnBoard(p1, pgo.Board())
+	c := genetic.NewGeneticContext()
+	c.RandomGenerator = rand.New(rand.NewSource(1))
+	realNodes := len(p1.Genome.Nodes)
+	maxWaypoints := 0
+
+	for i := 0; i < 500; i++ {
+		pgo.Mutate(p1, c)
+		pgo.Mutate(p2, c)
+		checkRouting(t, p1.Genome)
+		checkRouting(t, p2.Genome)
+
+		child := pgo.CrossOver(p1, p2, c)
+		checkRouting(t, child.Genome)
+
+		for n := range child.Genome.Nodes {
+			if child.Genome.IsWaypoint(n) != (n >= realNodes) {
+				t.Fatalf("Node %d of the child is out of place", n)
+			}
+		}
+
+		if len(child.Genome.Waypoints()) > maxWaypoints {
+			maxWaypoints = len(child.Genome.Waypoints())
+		}
+
+		p1, p2 = child, p1
+	}
+
+	if maxWaypoints == 0 {
+		t.Errorf("No waypoint was ever inherited")
+	}
+
+	pgo.Grow(p1, c)
+	if len(p1.Geometry.Nodes) != len(p1.Genome.Nodes) {
+		t.Errorf("Expected %d node polygons, got %d", len(p1.Genome.Nodes), len(p1.Geometry.Nodes))
+	}
+}
+
+func TestWaypointsStayPut(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 1, 100, 100, 4, 2, 0, pcb.MutationParams{
+		TranslateComponentGroupMutationWeight: 1,
+	}, pcb.EvaluationParams{})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 10, Y: 10, Component: 0}, {X: 90, Y: 90, Component: 1}},
+		Edges: []pcb.Edge{{From: 0, To: 1, Bend: true}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1}}},
+		Components: []pcb.Component{
+			{CX: 10, CY: 10, Nodes: []pcb.ComponentNode{{Node: 0}}},
+			{CX: 90, CY: 90, Nodes: []pcb.ComponentNode{{Node: 1}}},
+		},
+	})
+	waypoint := pcb.AddWaypoint(p.Genome, 0, 50, 20, nil)
+	p.Genome.Edges = []pcb.Edge{{From: 0, To: waypoint, Bend: true}, {From: waypoint, To: 1}}
+
+	// Scrumbling and translating groups of components only move the real ones
+	c := genetic.NewGeneticContext()
+	for i := 0; i < 20; i++ {
+		p = pcb.ScrumblePcbOnBoard(p, pgo.Board())
+		pgo.Mutate(p, c)
+
+		if n := p.Genome.Nodes[waypoint]; n.X != 50 || n.Y != 20 {
+			t.Fatalf("Waypoint moved to %v, %v", n.X, n.Y)
+		}
+	}
+
+	// Removing the waypoint keeps the bend of the first edge
+	pcb.RemoveWaypoint(p.Genome, waypoint)
+	if e := p.Genome.Edges; len(e) != 1 || !e[0].Bend {
+		t.Errorf("Expected a single edge with the bend of the first one, got %v", e)
+	}
+}
+
+func TestRoutingStyles(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 4, 2, 0, pcb.MutationParams{
+		FlipBendMutationWeight: 1,
+	}, pcb.EvaluationParams{SamePlaneIntersectionCost: 1, MinDist: 1})
+
+	// Edge 0 goes from the top left to the bottom right, node 4 lies where its horizontal-first path bends
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10},
+			{X: 50, Y: 30},
+			{X: 60, Y: 5},
+			{X: 60, Y: 20},
+			{X: 50, Y: 10},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{4}}},
+	})
+
+	cases := []struct {
+		style  pcb.RoutingStyle
+		bend   bool
+		length float64
+		hits   bool
+	}{
+		{pcb.STRAIGHT_ROUTING, false, math.Hypot(40, 20), false},
+		{pcb.ORTHOGONAL_ROUTING, false, 60, true},
+		{pcb.ORTHOGONAL_ROUTING, true, 60, false},
+		{pcb.OCTILINEAR_ROUTING, false, 20 + 20*math.Sqrt2, false},
+		{pcb.OCTILINEAR_ROUTING, true, 20 + 20*math.Sqrt2, false},
+	}
+
+	c := genetic.NewGeneticContext()
+
+	for _, tc := range cases {
+		pgo.SetRoutingStyle(tc.style)
+		p.Genome.Edges[0].Bend = tc.bend
+		pgo.Grow(p, c)
+
+		if length := pcb.GetTotalPcbLength(p); math.Abs(length-tc.length) > 1e-9 {
+			t.Errorf("Style %v, bend %v: expected length %v, got %v", tc.style, tc.bend, tc.length, length)
+		}
+
+		if hits := pgo.EvaluatePcbIntersections(p) > 0; hits != tc.hits {
+			t.Errorf("Style %v, bend %v: expected the edge to hit node 4: %v", tc.style, tc.bend, tc.hits)
+		}
+	}
+
+	bend := p.Genome.Edges[0].Bend
+	pgo.Mutate(p, c)
+	if p.Genome.Edges[0].Bend == bend {
+		t.Errorf("Expected the bend to be flipped")
+	}
+}
+
+func TestFacingBends(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		MinDist:                   2.5,
+	})
+	pgo.SetRoutingStyle(pcb.ORTHOGONAL_ROUTING)
+
+	// The corners of the edges are 3.5·√2 apart, their miters point at each other 1.5·√2 apart
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 20},
+			{X: 20, Y: 30},
+			{X: 33.5, Y: 16.5},
+			{X: 23.5, Y: 6.5},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}},
+	})
+
+	pgo.Grow(p, genetic.NewGeneticContext())
+
+	dist := geo.PolyDistance(p.Geometry.Edges[0], p.Geometry.Edges[1])
+	if math.Abs(dist-1.5*math.Sqrt2) > 1e-9 {
+		t.Fatalf("Expected the edges 1.5·√2 apart, got %v", dist)
+	}
+
+	found := false
+	for _, v := range pgo.CheckDesignRules(p) {
+		if v.Kind == pcb.CLEARANCE_VIOLATION && v.Objects[0] == (pcb.Object{Kind: pcb.EDGE_OBJECT, Index: 0}) && v.Objects[1] == (pcb.Object{Kind: pcb.EDGE_OBJECT, Index: 1}) {
+			found = math.Abs(v.Measured-dist) < 1e-9
+		}
+	}
+
+	if !found {
+		t.Errorf("Expected a clearance violation between the edges measuring %v, got %v", dist, pgo.CheckDesignRules(p))
+	}
+}
+
+func TestPadShapes(t *testing.T) {
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 20, Y: 50, Component: 0},
+			{X: 40, Y: 50, Component: 0},
+			{X: 60, Y: 50, Component: 0},
+			{X: 80, Y: 50, Component: 0},
+			{X: 50, Y: 80, Component: 0},
+		},
+		Components: []pcb.Component{{
+			Nodes: []pcb.ComponentNode{
+				{Node: 0, Shape: pcb.RECTANGLE_PAD, W: 4, H: 8},
+				{Node: 1, Shape: pcb.CIRCLE_PAD, W: 6},
+				{Node: 2, Shape: pcb.ROUNDED_RECTANGLE_PAD, W: 4, H: 8, Radius: 1},
+				{Node: 3, Shape: pcb.OVAL_PAD, W: 4, H: 8},
+				{Node: 4},
+			},
+			CX:       50,
+			CY:       50,
+			Rotation: 90,
+		}},
+	})
+
+	p.ComputeGeometry(2, 1)
+
+	// Pads turn with the component, the last one has no size and falls back to the node size
+	sizes := [][2]float64{{8, 4}, {6, 6}, {8, 4}, {8, 4}, {2, 2}}
+	for i, sz := range sizes {
+		b := p.Geometry.Nodes[i].Bounds()
+		if math.Abs(b.Max(0)-b.Min(0)-sz[0]) > 1e-9 || math.Abs(b.Max(1)-b.Min(1)-sz[1]) > 1e-9 {
+			t.Errorf("Node %d: expected a %vx%v bounding box, got %v", i, sz[0], sz[1], b)
+		}
+	}
+
+	// Arcs are approximated closely enough
+	if area := p.Geometry.Nodes[1].Area(); math.Abs(area-9*math.Pi) > 0.2 {
+		t.Errorf("Expected the circle area to be close to %v, got %v", 9*math.Pi, area)
+	}
+
+	if area := p.Geometry.Nodes[3].Area(); math.Abs(area-(16+4*math.Pi)) > 0.2 {
+		t.Errorf("Expected the oval area to be close to %v, got %v", 16+4*math.Pi, area)
+	}
+}
+
+func TestZones(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		UnconnectedCost: 10,
+		MinDist:         1,
+	})
+
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Zones = []pcb.Zone{{Net: 0, Plane: 1, Clearance: 2}}
+	pgo.SetBoard(board)
+
+	// Net 0 has no edges, net 1 crosses the whole board on the plane of the zone
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 20, Y: 50, Component: 0},
+			{X: 80, Y: 50, Component: 1},
+			{X: 50, Y: 1, Component: 2},
+			{X: 50, Y: 99, Component: 3},
+		},
+		Edges: []pcb.Edge{{From: 2, To: 3, Net: 1, Plane: 1}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}},
+	})
+
+	c := genetic.NewGeneticContext()
+	pgo.Grow(p, c)
+
+	// The zone is split in two by the edge, the pads of net 0 aren't connected
+	if cost := pgo.EvaluateUnconnectedNets(p); cost != 10 {
+		t.Errorf("Expected net 0 to be unconnected, got cost %v", cost)
+	}
+
+	area := 0.0
+	for _, f := range p.Geometry.ZoneFills[0] {
+		area += f.Area()
+	}
+
+	// The edge and its pads are cut out of the fill with the clearance of the zone
+	if area >= 100*100-100*(2+2*2) || area < 100*100-100*(2+2*2)-2*8*8 {
+		t.Errorf("Unexpected zone area %v", area)
+	}
+
+	// On the other plane the edge no longer splits the zone
+	p.Genome.Edges[0].Plane = 0
+	pgo.Grow(p, c)
+
+	if cost := pgo.EvaluateUnconnectedNets(p); cost != 0 {
+		t.Errorf("Expected the zone to connect net 0, got cost %v", cost)
+	}
+}
+
+func TestPartiallyRoutedZone(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 4, 2, 0, pcb.MutationParams{
+		RerouteEdgeMutationWeight: 1,
+		JunctionMutationWeight:    1,
+	}, pcb.EvaluationParams{})
+
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Zones = []pcb.Zone{{Net: 0, Plane: 1}}
+	pgo.SetBoard(board)
+
+	// A single edge joins two of the three pads of the zone net, removing it leaves three parts
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 20, Y: 50, Component: 0}, {X: 80, Y: 50, Component: 1}, {X: 50, Y: 80, Component: 2}},
+		Edges: []pcb.Edge{{From: 0, To: 1}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1, 2}}},
+	})
+
+	c := genetic.NewGeneticContext()
+	for i := 0; i < 20; i++ {
+		pgo.Mutate(p, c)
+	}
+
+	if len(p.Genome.Edges) != 1 || p.Genome.Edges[0].From != 0 || p.Genome.Edges[0].To != 1 {
+		t.Errorf("Expected the edge of the zone net to be left alone, got %v", p.Genome.Edges)
+	}
+}
+
+func TestZoneNetMutations(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 4, 2, 0, pcb.MutationParams{
+		RerouteEdgeMutationWeight: 1,
+		ChangePlaneMutationWeight: 1,
+		FlipBendMutationWeight:    1,
+		BreakEdgeMutationWeight:   1,
+		JunctionMutationWeight:    1,
+	}, pcb.EvaluationParams{
+		UnconnectedCost: 10,
+		MinDist:         1,
+	})
+
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Zones = []pcb.Zone{{Net: 0, Plane: 1, Clearance: 2}}
+	pgo.SetBoard(board)
+
+	// A long pad of net 1 on the bottom plane splits the zone in two, the pads 0 and 1 on one side
+	// and the pad 2 on the other
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 20, Y: 50, Component: 0},
+			{X: 30, Y: 50, Component: 1},
+			{X: 80, Y: 50, Component: 2},
+			{X: 50, Y: 50, Component: 3},
+		},
+		Edges: []pcb.Edge{},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1, 2}}, {Nodes: []int{3}}},
+		Components: []pcb.Component{
+			{Nodes: []pcb.ComponentNode{{Node: 0}}, CX: 20, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 1}}, CX: 30, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 2}}, CX: 80, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 3, Type: pcb.SMD_PAD, W: 2, H: 100}}, CX: 50, CY: 50, X1: -1, Y1: -1, X2: 1, Y2: 1, Side: pcb.BOTTOM_SIDE},
+		},
+	})
+
+	// Without edges there is nothing for the edge mutations to change
+	c := genetic.NewGeneticContext()
+	for i := 0; i < 20; i++ {
+		pgo.Mutate(p, c)
+	}
+
+	if len(p.Genome.Edges) != 0 {
+		t.Errorf("Expected no edges, got %v", p.Genome.Edges)
+	}
+
+	pgo = pcb.NewPcbGeneticOperators(1, 1, 0, 100, 100, 4, 2, 0, pcb.MutationParams{
+		RegenerateNetMutationWeight: 1,
+	}, pcb.EvaluationParams{
+		UnconnectedCost: 10,
+		MinDist:         1,
+	})
+	pgo.SetBoard(board)
+
+	// Regenerating the zone net only joins the two islands, regenerating net 1 does nothing
+	c.RandomGenerator = rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		pgo.Mutate(p, c)
+	}
+
+	if len(p.Genome.Edges) != 1 || (p.Genome.Edges[0].From == 2) == (p.Genome.Edges[0].To == 2) {
+		t.Fatalf("Expected a single edge between the islands, got %v", p.Genome.Edges)
+	}
+
+	pgo.Grow(p, c)
+
+	if cost := pgo.EvaluateUnconnectedNets(p); cost != 0 {
+		t.Errorf("Expected the edge and the zone to connect net 0, got cost %v", cost)
+	}
+}
+
+func TestIncrementalEvaluation(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 1, 0.1, 100, 100, 4, 2, 20, pcb.MutationParams{
+		GlobalMutationWeight:                  1,
+		TranslateComponentGroupMutationWeight: 1,
+		RegenerateNetMutationWeight:           1,
+		RotateComponentMutationWeight:         1,
+		RerouteEdgeMutationWeight:             1,
+		ChangePlaneMutationWeight:             1,
+		FlipComponentMutationWeight:           1,
+		BreakEdgeMutationWeight:               1,
+		JunctionMutationWeight:                1,
+		MoveWaypointMutationWeight:            1,
+		RemoveWaypointMutationWeight:          1,
+		MergeWaypointsMutationWeight:          1,
+		FlipBendMutationWeight:                1,
+	}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost:      1,
+		DifferentPlaneIntersectionCost: 0.5,
+		EdgeLengthCost:                 1,
+		OutOfBoundsCost:                1,
+		MinDist:                        1,
+		ComponentKeepoutCost:           1,
+		ViaCost:                        1,
+		RoutingKeepoutCost:             1,
+		UnconnectedCost:                1,
+	})
+	board := pcb.NewRectangularBoard(100, 100)
+	board.Keepouts = []pcb.Keepout{
+		{Kind: pcb.COMPONENT_KEEPOUT, Area: geom.NewPolygonFlat(geom.XY, []float64{0, 0, 20, 0, 20, 20, 0, 20, 0, 0}, []int{10})},
+		{Kind: pcb.ROUTING_KEEPOUT, Plane: 1, Area: geom.NewPolygonFlat(geom.XY, []float64{40, 0, 60, 0, 60, 100, 40, 100, 40, 0}, []int{10})},
+	}
+	pgo.SetBoard(board)
+	pgo.SetRoutingStyle(pcb.OCTILINEAR_ROUTING)
+	pgo.SetVerifyIncremental(true)
+
+	templates := []pcb.Component{
+		{Nodes: []pcb.ComponentNode{{DX: -2}, {DX: 2, Type: pcb.SMD_PAD}}, X1: -5, Y1: -5, X2: 5, Y2: 5},
+		{Nodes: []pcb.ComponentNode{{DX: -3}, {DX: 0}, {DX: 3}}, X1: -5, Y1: -5, X2: 5, Y2: 5},
+	}
+
+	c := genetic.NewGeneticContext()
+	c.RandomGenerator = rand.New(rand.NewSource(1))
+	p := pcb.GeneratePcbFullOnBoard(templates, 12, 4, board, rand.New(rand.NewSource(1)))
+	pop := []*pcb.Pcb{p, pcb.ScrumblePcbOnBoard(p, board)}
+
+	for _, p := range pop {
+		pgo.Grow(p, c)
+		pgo.Evaluate(p, c)
+	}
+
+	// Evaluate panics if the incremental cost of a child differs from the full one
+	for i := 0; i < 300; i++ {
+		p1, p2 := pop[c.RandomGenerator.Intn(len(pop))], pop[c.RandomGenerator.Intn(len(pop))]
+		child := pgo.CrossOver(p1, p2, c)
+		pgo.Mutate(child, c)
+		pgo.Grow(child, c)
+		pgo.Evaluate(child, c)
+
+		pop = append(pop, child)
+	}
+}
+
+func TestEvaluateDetailed(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		EdgeLengthCost:            1,
+		NonZeroPlaneEdgeCost:      2,
+		OutOfBoundsCost:           100,
+		UnconnectedCost:           10,
+		MinDist:                   2,
+	})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 50, Component: 1},
+			{X: 90, Y: 50, Component: 2},
+			{X: 50, Y: 10, Component: 3},
+			{X: 50, Y: 90, Component: 4},
+			{X: 10, Y: 90, Component: 5},
+			{X: 90, Y: 90, Component: 6},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1, Plane: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}, {Nodes: []int{4, 5}}},
+		Components: []pcb.Component{
+			{CX: -50, CY: 50, X1: -5, Y1: -5, X2: 5, Y2: 5},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	res := pgo.EvaluateDetailed(p)
+
+	expected := map[string]float64{
+		"intersections": 1,
+		"edge length":   160,
+		"layers":        1,
+		"out of bounds": 1,
+		"keepouts":      0,
+		"unconnected":   1,
+		"vias":          0,
+	}
+
+	for name, raw := range expected {
+		if term := res.Term(name); term == nil || math.Abs(term.Raw-raw) > 1e-9 {
+			t.Errorf("Expected %v %v, got %+v", raw, name, term)
+		}
+	}
+
+	if crossing := res.Term("intersections").Violations; len(crossing) != 1 || crossing[0].Kind != pcb.CROSSING_VIOLATION {
+		t.Errorf("Expected a crossing between the edges, got %v", crossing)
+	}
+
+	if fitness := pgo.Evaluate(p, genetic.NewGeneticContext()); math.Abs(res.Fitness-fitness) > 1e-9 || math.Abs(res.Cost+fitness) > 1e-9 {
+		t.Errorf("Expected the fitness %v of Evaluate, got %v (cost %v)", fitness, res.Fitness, res.Cost)
+	}
+}
+
+// bottomComponentsTerm counts the components mounted on the bottom side
+type bottomComponentsTerm struct {
+	weight float64
+}
+
+func (t *bottomComponentsTerm) Name() string {
+	return "bottom components"
+}
+
+func (t *bottomComponentsTerm) Weight() float64 {
+	return t.weight
+}
+
+func (t *bottomComponentsTerm) Evaluate(p *pcb.Pcb) float64 {
+	res := 0.0
+
+	for _, c := range p.Genome.Components {
+		if c.Side == pcb.BOTTOM_SIDE {
+			res++
+		}
+	}
+
+	return res
+}
+
+func init() {
+	pcb.RegisterCostTerm("bottom components", func(pgo *pcb.PcbGeneticOperators, weight float64, params json.RawMessage) (pcb.CostTerm, error) {
+		return &bottomComponentsTerm{weight}, nil
+	})
+}
+
+func TestCostTerms(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{OutOfBoundsCost: 100})
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 10, Y: 10, Component: 0}, {X: 50, Y: 50, Component: 1}},
+		Components: []pcb.Component{
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 10, CY: 10, Side: pcb.BOTTOM_SIDE},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 50, CY: 150},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	if _, err := pcb.NewCostTerm("top components", pgo, 1, nil); err == nil {
+		t.Errorf("Expected an error for an unregistered term")
+	}
+
+	term, err := pcb.NewCostTerm("bottom components", pgo, 3, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pgo.AddCostTerm(term)
+
+	if terms := pgo.CostTerms(); len(terms) != 12 || terms[len(terms)-1] != term {
+		t.Errorf("Expected the term after the 11 built-in ones, got %v", terms)
+	}
+
+	// One component out of the board, one on the bottom side
+	if fitness := pgo.Evaluate(p, genetic.NewGeneticContext()); fitness != -103 {
+		t.Errorf("Expected -103, got %v", fitness)
+	}
+
+	if b := pgo.EvaluateDetailed(p).Term("bottom components"); b == nil || b.Raw != 1 || b.Cost != 3 {
+		t.Errorf("Expected 1 bottom component costing 3, got %+v", b)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a built-in term name to panic")
+		}
+	}()
+	pcb.RegisterCostTerm("vias", nil)
+}
+
+func TestBoardArea(t *testing.T) {
+	params := pcb.EvaluationParams{BoardAreaCost: 1, MinDist: 1}
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 20, Y: 20, Component: 0}, {X: 60, Y: 40, Component: 1}, {X: 20, Y: 60, Component: 2}},
+		Edges: []pcb.Edge{{From: 0, To: 1}, {From: 1, To: 2}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1, 2}}},
+		Components: []pcb.Component{
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 20, CY: 20},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 60, CY: 40},
+			{X1: -5, Y1: -5, X2: 5, Y2: 5, CX: 20, CY: 60},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	// The components span 15..65 x 15..65, grown by the minimum distance
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, params)
+	box := pgo.TightOutline(p)
+
+	if b := box.Bounds(); b.Min(0) != 14 || b.Min(1) != 14 || b.Max(0) != 66 || b.Max(1) != 66 {
+		t.Errorf("Expected the 14..66 square, got %v", b)
+	}
+
+	if term := pgo.EvaluateDetailed(p).Term("board area"); term == nil || term.Raw != 52*52 || math.Abs(term.Cost-52*52/10000.0) > 1e-9 {
+		t.Errorf("Expected an area of %v, got %+v", 52*52, term)
+	}
+
+	// The hull cuts the corner of the square without components
+	params.BoardAreaHull = true
+	pgo = pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, params)
+	hull := pgo.TightOutline(p)
+
+	if hull.Area() >= box.Area() || !hull.Bounds().OverlapsPoint(geom.XY, geom.Coord{14, 14}) || !hull.Bounds().OverlapsPoint(geom.XY, geom.Coord{66, 66}) {
+		t.Errorf("Expected a hull smaller than %v with the same bounds, got %v with %v", box.Area(), hull.Area(), hull.Bounds())
+	}
+}
+
+func TestWirelengthTerms(t *testing.T) {
+	params := pcb.EvaluationParams{HpwlCost: 1, MstLengthCost: 1, SteinerLengthCost: 1}
+
+	// The waypoint in the middle of the net must not count
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 0, Y: 0, Component: 0}, {X: 30, Y: 0, Component: 1}, {X: 30, Y: 40, Component: 2}, {X: 90, Y: 90, Component: 3}},
+		Edges: []pcb.Edge{{From: 0, To: 3}, {From: 3, To: 1}, {From: 1, To: 2}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1, 2, 3}}},
+		Components: []pcb.Component{
+			{CX: 0, CY: 0},
+			{CX: 30, CY: 0},
+			{CX: 30, CY: 40},
+			{CX: 90, CY: 90, Kind: pcb.EDGE_BREAKER_COMPONENT},
+		},
+	})
+	p.ComputeGeometry(4, 2)
+
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, params)
+	b := pgo.EvaluateDetailed(p)
+
+	for name, raw := range map[string]float64{"hpwl": 70, "mst length": 70} {
+		if term := b.Term(name); term == nil || term.Raw != raw || math.Abs(term.Cost-raw/200) > 1e-9 {
+			t.Errorf("Expected %v of length %v, got %+v", name, raw, term)
+		}
+	}
+
+	if term := b.Term("steiner length"); term == nil || term.Raw > 70 || term.Raw < 50 {
+		t.Errorf("Expected a Steiner length between 50 and 70, got %+v", term)
+	}
+
+	// Moving the routing does not change them
+	p.Genome.Edges = []pcb.Edge{{From: 0, To: 1}, {From: 1, To: 2}}
+	p.ComputeGeometry(4, 2)
+
+	if term := pgo.EvaluateDetailed(p).Term("hpwl"); term.Raw != 70 {
+		t.Errorf("Expected the routing not to change the half perimeter, got %v", term.Raw)
+	}
+}
+
+func TestPhaseOperators(t *testing.T) {
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{{X: 10, Y: 10, Component: 0}, {X: 90, Y: 10, Component: 1}},
+		Edges: []pcb.Edge{{From: 0, To: 1}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1}}},
+		Components: []pcb.Component{
+			{CX: 10, CY: 10, Nodes: []pcb.ComponentNode{{Node: 0}}, Locked: pcb.LOCK_ROTATION},
+			{CX: 90, CY: 10, Nodes: []pcb.ComponentNode{{Node: 1}}},
+		},
+	})
+	pcb.AddWaypoint(p.Genome, 0, 50, 50, nil)
+
+	unrouted := pcb.WithoutRouting(p)
+	if g := unrouted.Genome; len(g.Edges) != 0 || len(g.Nodes) != 2 || len(g.Components) != 2 || len(g.Nets[0].Nodes) != 2 {
+		t.Errorf("Expected the pads only, got %+v", g)
+	}
+
+	frozen := pcb.FreezePlacement(unrouted)
+	if frozen.Genome.Components[1].Locked != pcb.LOCK_ALL || unrouted.Genome.Components[1].Locked != 0 {
+		t.Errorf("Expected a frozen copy, got %v", frozen.Genome.Components)
+	}
+
+	board := pcb.NewRectangularBoard(100, 100)
+	routed := pcb.RandomRouting(p, board, rand.New(rand.NewSource(1)))
+	if len(routed.Genome.Edges) != 1 || len(routed.Genome.Waypoints()) != 0 {
+		t.Errorf("Expected a single edge between the pads, got %v", routed.Genome.Edges)
+	}
+
+	// The zone connects its net, which is left without edges
+	board.Zones = []pcb.Zone{{Net: 0, Plane: 1}}
+	if routed := pcb.RandomRouting(p, board, rand.New(rand.NewSource(1))); len(routed.Genome.Edges) != 0 {
+		t.Errorf("Expected no edge for the zone net, got %v", routed.Genome.Edges)
+	}
+
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{})
+
+	for _, term := range pgo.PlacementOnly().CostTerms() {
+		if name := term.Name(); name == "edge length" || name == "layers" || name == "unconnected" || name == "vias" {
+			t.Errorf("Expected no routing term for the placement, got %v", name)
+		}
+	}
+
+	if terms := pgo.RoutingOnly().CostTerms(); len(terms) != len(pgo.CostTerms()) {
+		t.Errorf("Expected every term for the routing, got %d", len(terms))
+	}
+}
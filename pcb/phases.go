@@ -0,0 +1,118 @@
+package pcb
+
+import (
+	"math/rand"
+)
+
+// Names of the built-in terms that only depend on the routing, which placement only operators leave out
+var routingCostTerms = map[string]bool{"edge length": true, "layers": true, "unconnected": true, "vias": true}
+
+// placementOnly keeps the weights of the mutations moving, rotating and flipping real components.
+func (m MutationParams) placementOnly() MutationParams {
+	return MutationParams{
+		GlobalMutationWeight:                  m.GlobalMutationWeight,
+		TranslateComponentGroupMutationWeight: m.TranslateComponentGroupMutationWeight,
+		RotateComponentMutationWeight:         m.RotateComponentMutationWeight,
+		FlipComponentMutationWeight:           m.FlipComponentMutationWeight,
+		EdgeBreakerComponent:                  m.EdgeBreakerComponent,
+	}
+}
+
+// routingOnly keeps the weights of the mutations regenerating nets, rerouting edges and changing their plane.
+func (m MutationParams) routingOnly() MutationParams {
+	return MutationParams{
+		RegenerateNetMutationWeight: m.RegenerateNetMutationWeight,
+		RerouteEdgeMutationWeight:   m.RerouteEdgeMutationWeight,
+		ChangePlaneMutationWeight:   m.ChangePlaneMutationWeight,
+		EdgeBreakerComponent:        m.EdgeBreakerComponent,
+	}
+}
+
+// withMutations returns a copy of the operators using the given mutations and the cost terms
+// accepted by keep, the built-in ones being rebuilt for the copy.
+func (pgo *PcbGeneticOperators) withMutations(mutationParams MutationParams, keep func(CostTerm) bool) *PcbGeneticOperators {
+	res := *pgo
+	res.mutationParams = mutationParams
+	res.mutationChooser = res.buildMutationChooser()
+	res.costTerms = make([]CostTerm, 0, len(pgo.costTerms))
+
+	for _, t := range res.builtinTerms() {
+		if keep(t) {
+			res.costTerms = append(res.costTerms, t)
+		}
+	}
+
+	// Added terms follow the built-in ones
+	for _, t := range pgo.costTerms {
+		if !isBuiltinCostTerm(t.Name()) && keep(t) {
+			res.costTerms = append(res.costTerms, t)
+		}
+	}
+
+	return &res
+}
+
+// PlacementOnly returns a copy of the operators for pcbs without routing, as returned by
+// WithoutRouting: it only moves, rotates and flips components and leaves out the cost terms of the
+// routing, so that the placement is evaluated by the intersections of the components and pads,
+// the keepouts, the board area and the wirelength terms.
+func (pgo *PcbGeneticOperators) PlacementOnly() *PcbGeneticOperators {
+	return pgo.withMutations(pgo.mutationParams.placementOnly(), func(t CostTerm) bool {
+		return !routingCostTerms[t.Name()]
+	})
+}
+
+// RoutingOnly returns a copy of the operators which only regenerate nets, reroute edges and
+// change their plane, leaving the placement as it is.
+func (pgo *PcbGeneticOperators) RoutingOnly() *PcbGeneticOperators {
+	return pgo.withMutations(pgo.mutationParams.routingOnly(), func(t CostTerm) bool {
+		return true
+	})
+}
+
+// WithoutRouting returns a copy of p with its real components and pads only, without edges and waypoints.
+func WithoutRouting(p *Pcb) *Pcb {
+	res := p.Genome.copy()
+	realNodes := res.realNodes()
+
+	res.Nodes = res.Nodes[:realNodes]
+	res.Components = res.Components[:res.realComponents()]
+	res.Edges = make([]Edge, 0)
+
+	for i := range res.Nets {
+		nodes := make([]int, 0, len(res.Nets[i].Nodes))
+		for _, n := range res.Nets[i].Nodes {
+			if n < realNodes {
+				nodes = append(nodes, n)
+			}
+		}
+		res.Nets[i].Nodes = nodes
+	}
+
+	return NewPcb(res)
+}
+
+// FreezePlacement returns a copy of p whose real components are locked in place.
+func FreezePlacement(p *Pcb) *Pcb {
+	res := p.Genome.copy()
+
+	for i := 0; i < res.realComponents(); i++ {
+		res.Components[i].Locked = LOCK_ALL
+	}
+
+	return NewPcb(res)
+}
+
+// RandomRouting returns a copy of p with the waypoints removed and every net connected by a new
+// random spanning tree, except the nets of the zones of board which are left without edges.
+func RandomRouting(p *Pcb, board *Board, randomGenerator *rand.Rand) *Pcb {
+	res := WithoutRouting(p)
+
+	for net := range res.Genome.Nets {
+		if !board.HasZone(net) {
+			GenerateNet(res, net, randomGenerator)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,190 @@
+package pcb
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/twpayne/go-geom"
+)
+
+type LockKind int
+
+const (
+	LOCK_POSITION LockKind = 1 << iota
+	LOCK_ROTATION
+	LOCK_ALL = LOCK_POSITION | LOCK_ROTATION
+)
+
+// BoardEdge is a side of the board a component can be anchored to.
+type BoardEdge int
+
+const (
+	NO_EDGE BoardEdge = iota
+	LEFT_EDGE
+	TOP_EDGE
+	RIGHT_EDGE
+	BOTTOM_EDGE
+)
+
+var DefaultRotations = []float64{0, 90, 180, 270}
+
+func (c *Component) RandomRotation(randomGenerator *rand.Rand) float64 {
+	if c.ContinuousRotation {
+		return randomGenerator.Float64() * 360
+	}
+
+	rotations := c.Rotations
+	if len(rotations) == 0 {
+		rotations = DefaultRotations
+	}
+
+	return rotations[randomGenerator.Intn(len(rotations))]
+}
+
+func (c *Component) IsPositionLocked() bool {
+	return c.Locked&LOCK_POSITION != 0
+}
+
+func (c *Component) IsRotationLocked() bool {
+	return c.Locked&LOCK_ROTATION != 0
+}
+
+// outlineBoundary returns the crossing of the outer ring of outline closest to from along axis, on
+// the side of sign (-1 or 1), among the lines across the other axis between lo and hi. For a
+// component anchored to the left edge it is the outline edge left of its center the component
+// touches first over its whole height, where its left side goes. It returns false if no such
+// crossing exists.
+func outlineBoundary(outline *geom.Polygon, axis int, from, lo, hi, sign float64) (float64, bool) {
+	ring := outline.LinearRing(0).Coords()
+	other := 1 - axis
+
+	// The crossings are piecewise linear between the vertices, the closest one is on a line
+	// through a vertex or on one of the ends of the band
+	lines := []float64{lo, hi}
+	for _, c := range ring {
+		if c[other] > lo && c[other] < hi {
+			lines = append(lines, c[other])
+		}
+	}
+
+	res, found := 0.0, false
+	consider := func(v float64) {
+		if sign*(v-from) >= 0 && (!found || math.Abs(v-from) < math.Abs(res-from)) {
+			res, found = v, true
+		}
+	}
+
+	for _, t := range lines {
+		for j := 1; j < len(ring); j++ {
+			p, q := ring[j-1], ring[j]
+
+			switch {
+			case p[other] == q[other]:
+				if p[other] == t {
+					consider(p[axis])
+					consider(q[axis])
+				}
+			case (p[other]-t)*(q[other]-t) <= 0:
+				consider(p[axis] + (t-p[other])/(q[other]-p[other])*(q[axis]-p[axis]))
+			}
+		}
+	}
+
+	return res, found
+}
+
+// AnchorToBoardEdge moves an edge-anchored component perpendicularly to its board side so that its
+// outline touches the nearest outline edge on that side, leaving the position along the side
+// untouched. Components away from the outline go to the side of the board bounds.
+func AnchorToBoardEdge(c *Component, board *Board) {
+	if c.Anchor == NO_EDGE || c.IsPositionLocked() {
+		return
+	}
+
+	cb := componentToPoly(c).Bounds()
+	bb := board.Bounds()
+
+	switch c.Anchor {
+	case LEFT_EDGE:
+		x, ok := outlineBoundary(board.Outline, 0, c.CX, cb.Min(1), cb.Max(1), -1)
+		if !ok {
+			x = bb.Min(0)
+		}
+		c.CX += x - cb.Min(0)
+	case RIGHT_EDGE:
+		x, ok := outlineBoundary(board.Outline, 0, c.CX, cb.Min(1), cb.Max(1), 1)
+		if !ok {
+			x = bb.Max(0)
+		}
+		c.CX += x - cb.Max(0)
+	case TOP_EDGE:
+		y, ok := outlineBoundary(board.Outline, 1, c.CY, cb.Min(0), cb.Max(0), -1)
+		if !ok {
+			y = bb.Min(1)
+		}
+		c.CY += y - cb.Min(1)
+	case BOTTOM_EDGE:
+		y, ok := outlineBoundary(board.Outline, 1, c.CY, cb.Min(0), cb.Max(0), 1)
+		if !ok {
+			y = bb.Max(1)
+		}
+		c.CY += y - cb.Max(1)
+	}
+}
+
+// MoveComponent moves c to x, y unless its position is locked, keeping it on its anchor edge.
+func MoveComponent(c *Component, x, y float64, board *Board) {
+	if c.IsPositionLocked() {
+		return
+	}
+
+	c.CX, c.CY = x, y
+	AnchorToBoardEdge(c, board)
+}
+
+// RotateComponent rotates c unless its rotation is locked, keeping it on its anchor edge.
+func RotateComponent(c *Component, rotation float64, board *Board) {
+	if c.IsRotationLocked() {
+		return
+	}
+
+	c.Rotation = rotation
+	AnchorToBoardEdge(c, board)
+}
+
+// RandomizeComponentPosition moves c to a random position in the board, if its position is not locked.
+func RandomizeComponentPosition(c *Component, board *Board, randomGenerator *rand.Rand) {
+	if c.IsPositionLocked() {
+		return
+	}
+
+	x, y := GetComponentRandomPositionInBoard(c, board, randomGenerator)
+	MoveComponent(c, x, y, board)
+}
+
+// RandomizeComponentRotation gives c one of its allowed rotations at random, if its rotation is not locked.
+func RandomizeComponentRotation(c *Component, board *Board, randomGenerator *rand.Rand) {
+	RotateComponent(c, c.RandomRotation(randomGenerator), board)
+}
+
+// FlipComponent moves c to the other board side, mirroring its outline and pad offsets around its
+// vertical axis, unless it is locked: mirroring moves its pads, even if only its position is locked.
+func FlipComponent(c *Component, board *Board) {
+	if c.Locked != 0 {
+		return
+	}
+
+	if c.Side == TOP_SIDE {
+		c.Side = BOTTOM_SIDE
+	} else {
+		c.Side = TOP_SIDE
+	}
+
+	c.X1, c.X2 = -c.X2, -c.X1
+
+	for i := range c.Nodes {
+		c.Nodes[i].DX = -c.Nodes[i].DX
+	}
+
+	AnchorToBoardEdge(c, board)
+}
@@ -0,0 +1,64 @@
+package pcb
+
+import (
+	"math"
+
+	"github.com/twpayne/go-geom"
+)
+
+// RoutingStyle is the shape edges are realized with.
+type RoutingStyle int
+
+const (
+	// A straight segment between the edge nodes
+	STRAIGHT_ROUTING RoutingStyle = iota
+	// A horizontal and a vertical segment, Edge.Bend chooses which one comes first
+	ORTHOGONAL_ROUTING
+	// A 45 degrees diagonal segment and a horizontal or vertical one, Edge.Bend chooses which one comes first
+	OCTILINEAR_ROUTING
+)
+
+func sign(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+
+	return 1
+}
+
+// EdgePath returns the polyline an edge between from and to is realized with.
+func EdgePath(from, to Node, bend bool, style RoutingStyle) []geom.Coord {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	var corner geom.Coord
+
+	switch style {
+	case ORTHOGONAL_ROUTING:
+		if bend {
+			corner = geom.Coord{from.X, to.Y}
+		} else {
+			corner = geom.Coord{to.X, from.Y}
+		}
+	case OCTILINEAR_ROUTING:
+		d := math.Min(math.Abs(dx), math.Abs(dy))
+
+		if bend {
+			corner = geom.Coord{to.X - sign(dx)*d, to.Y - sign(dy)*d}
+		} else {
+			corner = geom.Coord{from.X + sign(dx)*d, from.Y + sign(dy)*d}
+		}
+	default:
+		return []geom.Coord{{from.X, from.Y}, {to.X, to.Y}}
+	}
+
+	return []geom.Coord{{from.X, from.Y}, corner, {to.X, to.Y}}
+}
+
+// pathReach returns how far the polygon of an edge of the given width extends from its path: half
+// the width, but the miter of a right angle bend reaches half the width times √2 from the corner.
+func pathReach(width float64, style RoutingStyle) float64 {
+	if style == STRAIGHT_ROUTING {
+		return width / 2
+	}
+
+	return width / 2 * math.Sqrt2
+}
@@ -0,0 +1,205 @@
+package pcb
+
+import (
+	"bufio"
+	"fmt"
+	"genetic_pcb/draw"
+	"html"
+	"image/color"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/twpayne/go-geom"
+)
+
+const svgToggleScript = `function toggle(id) {
+  var g = document.getElementById(id);
+  g.style.display = g.style.display === "none" ? "" : "none";
+}`
+
+// nodeNets returns the net of every node, or -1 for nodes that are not part of any net.
+func (g *Genome) nodeNets() []int {
+	res := make([]int, len(g.Nodes))
+
+	for i := range res {
+		res[i] = -1
+	}
+
+	for i, n := range g.Nets {
+		for _, node := range n.Nodes {
+			res[node] = i
+		}
+	}
+
+	return res
+}
+
+// usedPlanes returns the planes with edges or zones, sorted.
+func (g *Genome) usedPlanes(zones []Zone) []int {
+	planes := make(map[int]bool)
+
+	for _, e := range g.Edges {
+		planes[e.Plane] = true
+	}
+
+	for _, z := range zones {
+		planes[z.Plane] = true
+	}
+
+	res := make([]int, 0, len(planes))
+	for p := range planes {
+		res = append(res, p)
+	}
+
+	sort.Ints(res)
+
+	return res
+}
+
+// svgMultiPath returns the path data drawing all the polygons.
+func svgMultiPath(polys []*geom.Polygon) string {
+	paths := make([]string, len(polys))
+
+	for i, p := range polys {
+		paths[i] = draw.SvgPath(p)
+	}
+
+	return strings.Join(paths, " ")
+}
+
+func writeSvgPoly(w io.Writer, path, fill string, opacity float64, title string) {
+	fmt.Fprintf(w, "    <path d=\"%s\" fill=\"%s\" fill-opacity=\"%.2f\" fill-rule=\"evenodd\"><title>%s</title></path>\n", path, fill, opacity, title)
+}
+
+// WritePcbSvg renders the pcb on its board as an SVG document, with one group per plane
+// that can be toggled by clicking on the legend when the file is opened in a browser.
+func WritePcbSvg(w io.Writer, pcb *Pcb, board *Board, netColors []color.Color) error {
+	bw := bufio.NewWriter(w)
+	nodeNets := pcb.Genome.nodeNets()
+	planes := pcb.Genome.usedPlanes(board.Zones)
+	bounds := board.Bounds()
+	x, y, width, height := bounds.Min(0), bounds.Min(1), board.Width(), board.Height()
+
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" viewBox=\"%v %v %v %v\">\n", width, height, x, y, width, height)
+	fmt.Fprintf(bw, "  <script>%s</script>\n", svgToggleScript)
+	fmt.Fprintf(bw, "  <rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"black\"/>\n", x, y, width, height)
+	fmt.Fprintf(bw, "  <g id=\"board\">\n")
+	writeSvgPoly(bw, draw.SvgPath(board.Outline), "#003c00", 1, "board")
+	fmt.Fprintf(bw, "  </g>\n")
+	fmt.Fprintf(bw, "  <g id=\"keepouts\">\n")
+
+	for i, k := range board.Keepouts {
+		cl, _ := draw.SvgColor(keepoutColor(k))
+		title := fmt.Sprintf("keepout %d: component keepout", i)
+		if k.Kind == ROUTING_KEEPOUT {
+			title = fmt.Sprintf("keepout %d: routing keepout on plane %d", i, k.Plane)
+		}
+
+		writeSvgPoly(bw, draw.SvgPath(k.Area), cl, 0.3, title)
+	}
+
+	fmt.Fprintf(bw, "  </g>\n")
+
+	// Planes are drawn bottom first, edges filled with their layer color and outlined with their net color
+	for j := len(planes) - 1; j >= 0; j-- {
+		plane := planes[j]
+		layerColor, opacity := draw.SvgColor(board.LayerColor(plane))
+
+		fmt.Fprintf(bw, "  <g id=\"plane-%d\" class=\"plane\" fill=\"%s\" fill-opacity=\"%.2f\" stroke-width=\"1\">\n", plane, layerColor, opacity*0.8)
+
+		for i, fill := range pcb.Geometry.ZoneFills {
+			if z := board.Zones[i]; z.Plane == plane {
+				fmt.Fprintf(bw, "    <path d=\"%s\" stroke=\"none\" fill-opacity=\"%.2f\"><title>zone %d: net %d, plane %d</title></path>\n", svgMultiPath(fill), opacity*0.4, i, z.Net, plane)
+			}
+		}
+
+		for i, edge := range pcb.Genome.Edges {
+			if edge.Plane != plane {
+				continue
+			}
+
+			netColor, _ := draw.SvgColor(netColors[edge.Net%len(netColors)])
+			title := fmt.Sprintf("edge %d: net %d, nodes %d-%d, plane %d", i, edge.Net, edge.From, edge.To, plane)
+			if class := pcb.Genome.NetClass(edge.Net); class != nil {
+				title += ", class " + html.EscapeString(class.Name)
+			}
+			fmt.Fprintf(bw, "    <path d=\"%s\" stroke=\"%s\"><title>%s</title></path>\n", draw.SvgPath(pcb.Geometry.Edges[i]), netColor, title)
+		}
+
+		fmt.Fprintf(bw, "  </g>\n")
+	}
+
+	fmt.Fprintf(bw, "  <g id=\"vias\">\n")
+
+	for i, via := range pcb.Geometry.Vias {
+		site := pcb.Geometry.ViaSites[i]
+		title := fmt.Sprintf("via %d: net %d, node %d, planes %d-%d", i, site.Net, site.Node, site.FromPlane, site.ToPlane)
+		writeSvgPoly(bw, draw.SvgPath(via), "#b4b4b4", 1, title)
+	}
+
+	fmt.Fprintf(bw, "  </g>\n")
+	fmt.Fprintf(bw, "  <g id=\"pads\">\n")
+
+	for i, node := range pcb.Geometry.Nodes {
+		fill := "#ff0000"
+		if nodeNets[i] >= 0 {
+			fill, _ = draw.SvgColor(netColors[nodeNets[i]%len(netColors)])
+		}
+
+		title := fmt.Sprintf("node %d: component %d, net %d", i, pcb.Genome.Nodes[i].Component, nodeNets[i])
+		fmt.Fprintf(bw, "    <path d=\"%s\" fill=\"%s\" stroke=\"#ff0000\" stroke-width=\"1\"><title>%s</title></path>\n", draw.SvgPath(node), fill, title)
+		fmt.Fprintf(bw, "    <text x=\"%.3f\" y=\"%.3f\" font-size=\"8\" fill=\"#c8c8c8\" pointer-events=\"none\">%d</text>\n", pcb.Genome.Nodes[i].X, pcb.Genome.Nodes[i].Y, i)
+	}
+
+	fmt.Fprintf(bw, "  </g>\n")
+	fmt.Fprintf(bw, "  <g id=\"components\" fill=\"none\" stroke=\"white\" stroke-width=\"1\">\n")
+
+	for i, component := range pcb.Geometry.Components {
+		if pcb.Genome.Components[i].Kind == EDGE_BREAKER_COMPONENT {
+			continue
+		}
+
+		if pcb.Genome.Components[i].Side == BOTTOM_SIDE {
+			fmt.Fprintf(bw, "    <path d=\"%s\" stroke=\"#7878ff\" stroke-dasharray=\"4 2\"><title>component %d (bottom)</title></path>\n", draw.SvgPath(component), i)
+		} else {
+			fmt.Fprintf(bw, "    <path d=\"%s\"><title>component %d (top)</title></path>\n", draw.SvgPath(component), i)
+		}
+	}
+
+	fmt.Fprintf(bw, "  </g>\n")
+	fmt.Fprintf(bw, "  <g id=\"legend\" font-size=\"10\" fill=\"white\" cursor=\"pointer\">\n")
+
+	for i, plane := range planes {
+		name := fmt.Sprintf("plane %d", plane)
+		if plane < len(board.Layers) && board.Layers[plane].Name != "" {
+			name = board.Layers[plane].Name
+		}
+
+		fmt.Fprintf(bw, "    <text x=\"%v\" y=\"%v\" onclick=\"toggle('plane-%d')\">%s</text>\n", x+5, y+float64(12*(i+1)), plane, html.EscapeString(name))
+	}
+
+	fmt.Fprintf(bw, "    <text x=\"%v\" y=\"%v\" onclick=\"toggle('pads')\">pads</text>\n", x+5, y+float64(12*(len(planes)+1)))
+	fmt.Fprintf(bw, "    <text x=\"%v\" y=\"%v\" onclick=\"toggle('components')\">components</text>\n", x+5, y+float64(12*(len(planes)+2)))
+	fmt.Fprintf(bw, "    <text x=\"%v\" y=\"%v\" onclick=\"toggle('keepouts')\">keepouts</text>\n", x+5, y+float64(12*(len(planes)+3)))
+	fmt.Fprintf(bw, "    <text x=\"%v\" y=\"%v\" onclick=\"toggle('vias')\">vias</text>\n", x+5, y+float64(12*(len(planes)+4)))
+	fmt.Fprintf(bw, "  </g>\n")
+	fmt.Fprintf(bw, "</svg>\n")
+
+	return bw.Flush()
+}
+
+func DrawPcbToSvg(pcb *Pcb, board *Board, svgPath string, netColors []color.Color) error {
+	f, err := os.Create(svgPath)
+	if err != nil {
+		return err
+	}
+
+	if err := WritePcbSvg(f, pcb, board, netColors); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
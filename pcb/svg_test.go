@@ -0,0 +1,58 @@
+package pcb_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"genetic_pcb/pcb"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestWritePcbSvg(t *testing.T) {
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 10, Component: 0},
+			{X: 50, Y: 10, Component: 1},
+			{X: 50, Y: 50, Component: 1},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0, Plane: 0},
+			{From: 1, To: 2, Net: 0, Plane: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1, 2}}},
+	})
+	p.ComputeGeometry(10, 5)
+
+	buf := bytes.Buffer{}
+	if err := pcb.WritePcbSvg(&buf, p, pcb.NewRectangularBoard(100, 100), []color.Color{color.RGBA{255, 0, 0, 255}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	groups := make(map[string]bool)
+	decoder := xml.NewDecoder(&buf)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid SVG: %v", err)
+		}
+
+		if se, ok := token.(xml.StartElement); ok && se.Name.Local == "g" {
+			for _, a := range se.Attr {
+				if a.Name.Local == "id" {
+					groups[a.Value] = true
+				}
+			}
+		}
+	}
+
+	for _, id := range []string{"board", "plane-0", "plane-1", "pads", "components"} {
+		if !groups[id] {
+			t.Errorf("Missing group %q", id)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package pcb
+
+import (
+	"genetic_pcb/geo"
+	"sort"
+
+	"github.com/twpayne/go-geom"
+)
+
+// Number of sides of the polygon approximating a via
+const viaSides = 8
+
+// Via connects the planes FromPlane to ToPlane of a net at a node where the net switches plane.
+type Via struct {
+	Node      int
+	Net       int
+	X         float64
+	Y         float64
+	FromPlane int
+	ToPlane   int
+}
+
+// ComputeVias returns a via for every non through-hole node where edges on different planes meet,
+// or where an edge reaches an SMD pad from another plane.
+func (g *Genome) ComputeVias(planes int) []Via {
+	minPlanes := make(map[int]int)
+	maxPlanes := make(map[int]int)
+	nets := make(map[int]int)
+
+	addPlane := func(node, plane int) {
+		if p, ok := minPlanes[node]; !ok || plane < p {
+			minPlanes[node] = plane
+		}
+
+		if p, ok := maxPlanes[node]; !ok || plane > p {
+			maxPlanes[node] = plane
+		}
+	}
+
+	for _, e := range g.Edges {
+		for _, n := range []int{e.From, e.To} {
+			plane, smd := g.PadPlane(n, planes)
+
+			if !smd && !g.IsWaypoint(n) {
+				continue
+			}
+
+			if smd {
+				addPlane(n, plane)
+			}
+
+			addPlane(n, e.Plane)
+			nets[n] = e.Net
+		}
+	}
+
+	res := make([]Via, 0)
+
+	for n, minPlane := range minPlanes {
+		if maxPlanes[n] != minPlane {
+			res = append(res, Via{
+				Node:      n,
+				Net:       nets[n],
+				X:         g.Nodes[n].X,
+				Y:         g.Nodes[n].Y,
+				FromPlane: minPlane,
+				ToPlane:   maxPlanes[n],
+			})
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Node < res[j].Node })
+
+	return res
+}
+
+func viaToPoly(v *Via, viaSz float64) *geom.Polygon {
+	return geo.RegularPolygon(v.X, v.Y, viaSz/2, viaSides)
+}
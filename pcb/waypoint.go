@@ -0,0 +1,221 @@
+package pcb
+
+// Waypoints are single node EDGE_BREAKER_COMPONENTs belonging to a net, that split its edges so that
+// traces can bend and branch (Steiner points). They are always stored after the real components and
+// nodes, so that real ones keep the same indices in every individual.
+
+// AddWaypoint appends a waypoint of the net at x, y, shaped after template if not nil, and returns its node.
+func AddWaypoint(g *Genome, net int, x, y float64, template *Component) int {
+	var c *Component
+	if template != nil {
+		c = template.copy()
+	} else {
+		c = &Component{}
+	}
+
+	node := len(g.Nodes)
+
+	c.Kind = EDGE_BREAKER_COMPONENT
+	c.Nodes = []ComponentNode{{Node: node}}
+	c.CX, c.CY = x, y
+	c.Rotation = 0
+
+	g.Components = append(g.Components, *c)
+	g.Nodes = append(g.Nodes, Node{X: x, Y: y, Component: len(g.Components) - 1})
+	g.Nets[net].Nodes = append(g.Nets[net].Nodes, node)
+
+	return node
+}
+
+// MoveWaypoint moves the waypoint node and its component to x, y.
+func MoveWaypoint(g *Genome, node int, x, y float64) {
+	g.Nodes[node].X, g.Nodes[node].Y = x, y
+	c := &g.Components[g.Nodes[node].Component]
+	c.CX, c.CY = x, y
+}
+
+// Waypoints returns the nodes of all the waypoints.
+func (g *Genome) Waypoints() []int {
+	res := make([]int, 0)
+
+	for i := range g.Nodes {
+		if g.IsWaypoint(i) {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// realNodes returns how many nodes belong to real components, which come before waypoints.
+func (g *Genome) realNodes() int {
+	for i := range g.Nodes {
+		if g.IsWaypoint(i) {
+			return i
+		}
+	}
+
+	return len(g.Nodes)
+}
+
+// realComponents returns how many components are real, which come before waypoints.
+func (g *Genome) realComponents() int {
+	for i, c := range g.Components {
+		if c.Kind == EDGE_BREAKER_COMPONENT {
+			return i
+		}
+	}
+
+	return len(g.Components)
+}
+
+// nodeEdges returns the indices of the edges ending in node.
+func (g *Genome) nodeEdges(node int) []int {
+	res := make([]int, 0)
+
+	for i, e := range g.Edges {
+		if e.From == node || e.To == node {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
+
+// otherEnd returns the end of the edge that is not node.
+func (e *Edge) otherEnd(node int) int {
+	if e.From == node {
+		return e.To
+	}
+
+	return e.From
+}
+
+// splitEdge breaks the edge in two at a new waypoint placed at x, y, returning its node.
+func splitEdge(g *Genome, edgeIndex int, x, y float64, template *Component) int {
+	edge := g.Edges[edgeIndex]
+	node := AddWaypoint(g, edge.Net, x, y, template)
+
+	g.Edges[edgeIndex].To = node
+	g.Edges = append(g.Edges, Edge{From: node, To: edge.To, Net: edge.Net, Plane: edge.Plane, Bend: edge.Bend})
+
+	return node
+}
+
+// RemoveWaypoint deletes the waypoint node, chaining its neighbors so that its net stays connected,
+// and compacts the indices of the nodes and components that followed it. Every chaining edge keeps
+// the bend of the edge of its first neighbor.
+func RemoveWaypoint(g *Genome, node int) {
+	edges := g.nodeEdges(node)
+	neighbors := make([]int, len(edges))
+	planes := make([]int, len(edges))
+	bends := make([]bool, len(edges))
+
+	for i, e := range edges {
+		neighbors[i] = g.Edges[e].otherEnd(node)
+		planes[i] = g.Edges[e].Plane
+		bends[i] = g.Edges[e].Bend
+	}
+
+	net := g.nodeNet(node)
+	res := make([]Edge, 0, len(g.Edges))
+
+	for _, e := range g.Edges {
+		if e.From != node && e.To != node {
+			res = append(res, e)
+		}
+	}
+
+	for i := 1; i < len(neighbors); i++ {
+		res = append(res, Edge{From: neighbors[i-1], To: neighbors[i], Net: net, Plane: planes[i], Bend: bends[i-1]})
+	}
+
+	g.Edges = res
+	g.removeNode(node)
+}
+
+// removeNode deletes a waypoint node and its component from g, which must not be used by any edge.
+func (g *Genome) removeNode(node int) {
+	component := g.Nodes[node].Component
+
+	g.Nodes = append(g.Nodes[:node], g.Nodes[node+1:]...)
+	g.Components = append(g.Components[:component], g.Components[component+1:]...)
+
+	for i := range g.Nodes {
+		if g.Nodes[i].Component > component {
+			g.Nodes[i].Component--
+		}
+	}
+
+	for i := range g.Components {
+		for j := range g.Components[i].Nodes {
+			if g.Components[i].Nodes[j].Node > node {
+				g.Components[i].Nodes[j].Node--
+			}
+		}
+	}
+
+	for i := range g.Edges {
+		if g.Edges[i].From > node {
+			g.Edges[i].From--
+		}
+
+		if g.Edges[i].To > node {
+			g.Edges[i].To--
+		}
+	}
+
+	for i := range g.Nets {
+		nodes := make([]int, 0, len(g.Nets[i].Nodes))
+
+		for _, n := range g.Nets[i].Nodes {
+			if n > node {
+				nodes = append(nodes, n-1)
+			} else if n < node {
+				nodes = append(nodes, n)
+			}
+		}
+
+		g.Nets[i].Nodes = nodes
+	}
+}
+
+// MergeWaypoints contracts the edge between waypoints n1 and n2 into n1, placed halfway between them.
+func MergeWaypoints(g *Genome, n1, n2 int) {
+	x, y := (g.Nodes[n1].X+g.Nodes[n2].X)/2, (g.Nodes[n1].Y+g.Nodes[n2].Y)/2
+	MoveWaypoint(g, n1, x, y)
+
+	res := make([]Edge, 0, len(g.Edges))
+
+	for _, e := range g.Edges {
+		if (e.From == n1 && e.To == n2) || (e.From == n2 && e.To == n1) {
+			continue
+		}
+
+		if e.From == n2 {
+			e.From = n1
+		}
+
+		if e.To == n2 {
+			e.To = n1
+		}
+
+		res = append(res, e)
+	}
+
+	g.Edges = res
+	g.removeNode(n2)
+}
+
+// nodeNet returns the net of the node, or -1 if it isn't part of any net.
+func (g *Genome) nodeNet(node int) int {
+	for i, n := range g.Nets {
+		for _, nn := range n.Nodes {
+			if nn == node {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
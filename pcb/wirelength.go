@@ -0,0 +1,66 @@
+package pcb
+
+import (
+	"genetic_pcb/geo"
+
+	"github.com/twpayne/go-geom"
+)
+
+// NetPads returns the positions of the pads of the net, leaving out the waypoints of its edges.
+func (g *Genome) NetPads(net int) []geom.Coord {
+	res := make([]geom.Coord, 0, len(g.Nets[net].Nodes))
+
+	for _, n := range g.Nets[net].Nodes {
+		if !g.IsWaypoint(n) {
+			res = append(res, geom.Coord{g.Nodes[n].X, g.Nodes[n].Y})
+		}
+	}
+
+	return res
+}
+
+// wirelengthTerm charges an estimate of the length of the nets computed from the positions of their
+// pads alone, relative to the half perimeter of the board. Unlike the edge length it only depends on
+// the placement of the components, not on the routing of the nets.
+type wirelengthTerm struct {
+	pgo    *PcbGeneticOperators
+	name   string
+	weight func(EvaluationParams) float64
+	length func([]geom.Coord) float64
+}
+
+func (t *wirelengthTerm) Name() string {
+	return t.name
+}
+
+func (t *wirelengthTerm) Weight() float64 {
+	return t.weight(t.pgo.evaluationParams)
+}
+
+func (t *wirelengthTerm) total(pcb *Pcb) float64 {
+	res := 0.0
+
+	for net := range pcb.Genome.Nets {
+		res += t.length(pcb.Genome.NetPads(net))
+	}
+
+	return res
+}
+
+func (t *wirelengthTerm) Evaluate(pcb *Pcb) float64 {
+	return t.total(pcb) / (t.pgo.board.Width() + t.pgo.board.Height())
+}
+
+func (t *wirelengthTerm) Breakdown(pcb *Pcb) TermBreakdown {
+	length := t.total(pcb)
+
+	return TermBreakdown{Name: t.Name(), Raw: length, Cost: t.Weight() * length / (t.pgo.board.Width() + t.pgo.board.Height())}
+}
+
+func (pgo *PcbGeneticOperators) wirelengthTerms() []CostTerm {
+	return []CostTerm{
+		&wirelengthTerm{pgo, "hpwl", func(p EvaluationParams) float64 { return p.HpwlCost }, geo.HalfPerimeter},
+		&wirelengthTerm{pgo, "mst length", func(p EvaluationParams) float64 { return p.MstLengthCost }, geo.MinimumSpanningTreeLength},
+		&wirelengthTerm{pgo, "steiner length", func(p EvaluationParams) float64 { return p.SteinerLengthCost }, geo.SteinerTreeLength},
+	}
+}
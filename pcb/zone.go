@@ -0,0 +1,228 @@
+package pcb
+
+import (
+	"genetic_pcb/geo"
+	"math/rand"
+	"sort"
+
+	"github.com/twpayne/go-geom"
+)
+
+// Zone is a copper pour of a net on a plane, filled inside its outline around the copper of the
+// other nets. The pads of its net it touches are connected through it, so the net needs no edges
+// between them.
+type Zone struct {
+	Net   int
+	Plane int
+	// Outline is the area of the zone, the whole board if nil
+	Outline *geom.Polygon
+	// Clearance is the distance the fill keeps from the copper of other nets
+	Clearance float64
+}
+
+// HasZone tells whether the net is poured in a zone of the board.
+func (b *Board) HasZone(net int) bool {
+	for _, z := range b.Zones {
+		if z.Net == net {
+			return true
+		}
+	}
+
+	return false
+}
+
+// zoneObstacles returns the copper of the other nets on the plane of the zone, grown by its clearance.
+func (pcb *Pcb) zoneObstacles(z *Zone, params GeometryParams, nodeNets []int, nodePlanes [][]bool) []*geom.Polygon {
+	res := make([]*geom.Polygon, 0)
+
+	for i, n := range pcb.Geometry.Nodes {
+		if nodeNets[i] != z.Net && z.Plane < len(nodePlanes[i]) && nodePlanes[i][z.Plane] {
+			res = append(res, geo.OffsetConvex(n, z.Clearance))
+		}
+	}
+
+	// Edges are split in segments, whose outlines are convex
+	for i, e := range pcb.Genome.Edges {
+		if e.Net == z.Net || e.Plane != z.Plane {
+			continue
+		}
+
+		width := pcb.Genome.EdgeWidth(e.Net, params.EdgeSz)
+		path := pcb.Geometry.EdgePaths[i]
+
+		for j := 1; j < len(path); j++ {
+			res = append(res, geo.OffsetConvex(geo.PolylineToPolygon(path[j-1:j+1], width), z.Clearance))
+		}
+	}
+
+	for i, v := range pcb.Geometry.Vias {
+		if pcb.Geometry.ViaSites[i].Net != z.Net {
+			res = append(res, geo.OffsetConvex(v, z.Clearance))
+		}
+	}
+
+	for _, k := range params.Board.Keepouts {
+		if k.Kind == ROUTING_KEEPOUT && k.Plane == z.Plane {
+			res = append(res, k.Area)
+		}
+	}
+
+	return res
+}
+
+// fillZones computes the fill of every zone of the board of params, once the rest of the geometry is known.
+func (pcb *Pcb) fillZones(params GeometryParams) [][]*geom.Polygon {
+	if params.Board == nil || len(params.Board.Zones) == 0 {
+		return nil
+	}
+
+	nodeNets := pcb.Genome.nodeNets()
+	nodePlanes := pcb.Genome.NodePlanes(params.Planes)
+	res := make([][]*geom.Polygon, len(params.Board.Zones))
+
+	for i := range params.Board.Zones {
+		z := &params.Board.Zones[i]
+		inside := []*geom.Polygon{params.Board.Outline}
+		if z.Outline != nil {
+			inside = append(inside, z.Outline)
+		}
+
+		res[i] = geo.Clip(inside, pcb.zoneObstacles(z, params, nodeNets, nodePlanes))
+	}
+
+	return res
+}
+
+// zoneIslands returns for every polygon of a zone fill the index of the connected piece of copper it belongs to.
+func zoneIslands(fill []*geom.Polygon) []int {
+	parent := make([]int, len(fill))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(n int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+
+	bounds := make([]*geom.Bounds, len(fill))
+	order := make([]int, len(fill))
+
+	for i, p := range fill {
+		bounds[i] = p.Bounds()
+		order[i] = i
+	}
+
+	sort.Slice(order, func(i, j int) bool { return bounds[order[i]].Min(1) < bounds[order[j]].Min(1) })
+
+	for i, p1 := range order {
+		for _, p2 := range order[i+1:] {
+			if bounds[p2].Min(1) > bounds[p1].Max(1) {
+				break
+			}
+
+			if !boundsTooFar(bounds[p1], bounds[p2], 0) && geo.PolyDistance(fill[p1], fill[p2]) == 0 {
+				parent[find(p1)] = find(p2)
+			}
+		}
+	}
+
+	for i := range parent {
+		parent[i] = find(i)
+	}
+
+	return parent
+}
+
+// zoneConnections calls connect for every pair of nodes joined by the fill of a zone of their net.
+func (pcb *Pcb) zoneConnections(zones []Zone, planes int, connect func(n1, n2 int)) {
+	if len(pcb.Geometry.ZoneFills) == 0 {
+		return
+	}
+
+	nodeNets := pcb.Genome.nodeNets()
+	nodePlanes := pcb.Genome.NodePlanes(planes)
+
+	for i, z := range zones {
+		fill := pcb.Geometry.ZoneFills[i]
+		islands := zoneIslands(fill)
+		first := make(map[int]int)
+
+		for n, poly := range pcb.Geometry.Nodes {
+			if nodeNets[n] != z.Net || z.Plane >= planes || !nodePlanes[n][z.Plane] {
+				continue
+			}
+
+			b := poly.Bounds()
+
+			for j, f := range fill {
+				if boundsTooFar(b, f.Bounds(), 0) || geo.PolyDistance(poly, f) != 0 {
+					continue
+				}
+
+				if other, ok := first[islands[j]]; ok {
+					connect(other, n)
+				} else {
+					first[islands[j]] = n
+				}
+			}
+		}
+	}
+}
+
+// connectZoneIslands adds random edges to the net, poured in a zone, between its nodes that the
+// fill of the zones leaves apart, so that the edges and the fill connect the net together.
+func (pcb *Pcb) connectZoneIslands(net int, params GeometryParams, randomGenerator *rand.Rand) {
+	pcb.ComputeGeometryWithParams(params)
+
+	parent := make([]int, len(pcb.Genome.Nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(n int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+
+	for _, e := range pcb.Genome.Edges {
+		if e.Net == net {
+			parent[find(e.From)] = find(e.To)
+		}
+	}
+
+	if params.Board != nil {
+		pcb.zoneConnections(params.Board.Zones, params.Planes, func(n1, n2 int) {
+			parent[find(n1)] = find(n2)
+		})
+	}
+
+	// The nodes of the net grouped by the piece of copper they are on
+	groups := make([][]int, 0)
+	groupOf := make(map[int]int)
+
+	for _, n := range pcb.Genome.Nets[net].Nodes {
+		root := find(n)
+		if g, ok := groupOf[root]; ok {
+			groups[g] = append(groups[g], n)
+		} else {
+			groupOf[root] = len(groups)
+			groups = append(groups, []int{n})
+		}
+	}
+
+	for i := 1; i < len(groups); i++ {
+		from := groups[i][randomGenerator.Intn(len(groups[i]))]
+		to := groups[randomGenerator.Intn(i)]
+
+		pcb.Genome.Edges = append(pcb.Genome.Edges, Edge{From: from, To: to[randomGenerator.Intn(len(to))], Net: net})
+	}
+
+	SortPcbEdges(pcb)
+}
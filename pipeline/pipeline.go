@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"fmt"
+	"genetic_pcb/genetic"
+	"genetic_pcb/pcb"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Phase is the operators and the number of generations of one of the genetic algorithms.
+type Phase struct {
+	Operators   *pcb.PcbGeneticOperators
+	Generations int
+}
+
+type Params struct {
+	// PopulationSize is the number of individuals of both genetic algorithms, 1000 if zero
+	PopulationSize int
+	// ElitarismKeepN is the number of best individuals kept in the next generation, 10 if zero
+	ElitarismKeepN int
+	// SecondParentRandomProb is the probability of choosing the second parent regardless of its fitness, 0.1 if zero
+	SecondParentRandomProb float64
+	// Parallelism is the number of children generated at the same time, 10 if zero
+	Parallelism int
+	// SelfReproductionProb is the probability of crossing an individual with itself, 0.01 if zero
+	SelfReproductionProb float64
+	// OnGeneration, if not nil, is called after every generation with the name of the phase
+	OnGeneration func(phase string, stats genetic.Statistics[*pcb.Pcb], details fmt.Stringer)
+	// Explain makes OnGeneration receive the breakdown of the evaluation of the best individual,
+	// which is a full evaluation per generation, instead of nil
+	Explain bool
+}
+
+// Pipeline optimizes the placement of a pcb and its routing one after the other: a first genetic
+// algorithm moves the components of the pcb stripped of its routing, then a second one routes the
+// nets of its best individual with the placement frozen.
+type Pipeline struct {
+	placement Phase
+	routing   Phase
+	params    Params
+}
+
+// PhaseReport is the outcome of one of the genetic algorithms.
+type PhaseReport struct {
+	Name        string
+	Generations int
+	Duration    time.Duration
+	Best        *pcb.Pcb
+	// Breakdown is the evaluation of the best individual by the operators of the phase
+	Breakdown *pcb.Breakdown
+}
+
+// Report is the outcome of both phases, the best individual of the routing phase being the result.
+type Report struct {
+	Placement PhaseReport
+	Routing   PhaseReport
+}
+
+func (r *Report) String() string {
+	sb := strings.Builder{}
+
+	for _, p := range []PhaseReport{r.Placement, r.Routing} {
+		fmt.Fprintf(&sb, "%s: %d generations in %s\n", p.Name, p.Generations, p.Duration.Round(time.Millisecond))
+		sb.WriteString(p.Breakdown.String())
+	}
+
+	return sb.String()
+}
+
+// New returns a pipeline running the placement phase with placement.Operators.PlacementOnly() and
+// the routing phase with routing.Operators.RoutingOnly().
+func New(placement, routing Phase, params Params) *Pipeline {
+	if params.PopulationSize <= 0 {
+		params.PopulationSize = 1000
+	}
+
+	if params.ElitarismKeepN <= 0 {
+		params.ElitarismKeepN = 10
+	}
+
+	if params.SecondParentRandomProb <= 0 {
+		params.SecondParentRandomProb = 0.1
+	}
+
+	if params.Parallelism <= 0 {
+		params.Parallelism = 10
+	}
+
+	if params.SelfReproductionProb <= 0 {
+		params.SelfReproductionProb = 0.01
+	}
+
+	placement.Operators = placement.Operators.PlacementOnly()
+	routing.Operators = routing.Operators.RoutingOnly()
+
+	return &Pipeline{placement: placement, routing: routing, params: params}
+}
+
+// run evolves the initial population for the generations of phase and reports its best individual.
+func (pl *Pipeline) run(name string, phase Phase, initialPop []*pcb.Pcb) PhaseReport {
+	start := time.Now()
+
+	ga := genetic.NewGeneticAlgorithm[*pcb.Pcb](
+		initialPop,
+		pl.params.ElitarismKeepN,
+		pl.params.SecondParentRandomProb,
+		phase.Operators,
+		pl.params.Parallelism,
+		pl.params.SelfReproductionProb,
+	)
+
+	for i := 0; i < phase.Generations; i++ {
+		ga.ComputeNextGeneration()
+
+		if pl.params.OnGeneration != nil {
+			var details fmt.Stringer
+			if pl.params.Explain {
+				details = ga.Explain()
+			}
+
+			pl.params.OnGeneration(name, ga.Statistics(), details)
+		}
+	}
+
+	best := ga.CurrentPop[0].Individual
+
+	return PhaseReport{
+		Name:        name,
+		Generations: phase.Generations,
+		Duration:    time.Since(start),
+		Best:        best,
+		Breakdown:   phase.Operators.EvaluateDetailed(best),
+	}
+}
+
+// Run optimizes the placement of p, then routes it. The routing of p is discarded, the locks of its
+// components are kept.
+func (pl *Pipeline) Run(p *pcb.Pcb) *Report {
+	randomGenerator := rand.New(rand.NewSource(time.Now().UnixNano()))
+	unrouted := pcb.WithoutRouting(p)
+	board := pl.placement.Operators.Board()
+
+	initialPop := make([]*pcb.Pcb, pl.params.PopulationSize)
+	for i := range initialPop {
+		initialPop[i] = pcb.ScrumblePcbOnBoard(unrouted, board)
+	}
+
+	res := &Report{Placement: pl.run("placement", pl.placement, initialPop)}
+
+	// Every individual of the routing phase shares the best placement, with its own random routes
+	frozen := pcb.FreezePlacement(res.Placement.Best)
+
+	initialPop = make([]*pcb.Pcb, pl.params.PopulationSize)
+	for i := range initialPop {
+		initialPop[i] = pcb.RandomRouting(frozen, board, randomGenerator)
+	}
+
+	res.Routing = pl.run("routing", pl.routing, initialPop)
+
+	for i := range unrouted.Genome.Components {
+		res.Routing.Best.Genome.Components[i].Locked = unrouted.Genome.Components[i].Locked
+	}
+
+	return res
+}
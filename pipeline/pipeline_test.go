@@ -0,0 +1,98 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"genetic_pcb/genetic"
+	"genetic_pcb/pcb"
+	"genetic_pcb/pipeline"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	templates := []pcb.Component{
+		{Nodes: []pcb.ComponentNode{{DX: -15}, {DX: 15}}, X1: -25, Y1: -10, X2: 25, Y2: 10},
+	}
+	p := pcb.GeneratePcbFull(templates, 6, 3, 300, 300, rand.New(rand.NewSource(1)))
+	p.Genome.Components[0].Locked = pcb.LOCK_POSITION
+	cx, cy := p.Genome.Components[0].CX, p.Genome.Components[0].CY
+
+	pgo := pcb.NewPcbGeneticOperators(1, 0.5, 0.2, 300, 300, 4, 2, 10,
+		pcb.MutationParams{
+			GlobalMutationWeight:          1,
+			RotateComponentMutationWeight: 1,
+			RegenerateNetMutationWeight:   1,
+			RerouteEdgeMutationWeight:     1,
+			BreakEdgeMutationWeight:       1,
+		},
+		pcb.EvaluationParams{SamePlaneIntersectionCost: 1, EdgeLengthCost: 0.1, OutOfBoundsCost: 100, UnconnectedCost: 10, HpwlCost: 1, MinDist: 1},
+	)
+
+	pgo.SetVerifyIncremental(true)
+
+	generations := map[string]int{}
+	pl := pipeline.New(pipeline.Phase{Operators: pgo, Generations: 3}, pipeline.Phase{Operators: pgo, Generations: 2}, pipeline.Params{
+		PopulationSize: 20,
+		ElitarismKeepN: 2,
+		Parallelism:    2,
+		OnGeneration: func(phase string, stats genetic.Statistics[*pcb.Pcb], details fmt.Stringer) {
+			generations[phase]++
+
+			if details != nil {
+				t.Errorf("Expected no breakdown unless asked for, got %v", details)
+			}
+		},
+	})
+	report := pl.Run(p)
+
+	if generations["placement"] != 3 || generations["routing"] != 2 {
+		t.Errorf("Expected 3 placement and 2 routing generations, got %v", generations)
+	}
+
+	placed, routed := report.Placement.Best.Genome, report.Routing.Best.Genome
+
+	if len(placed.Edges) != 0 || report.Placement.Breakdown.Term("edge length") != nil || report.Placement.Breakdown.Term("hpwl") == nil {
+		t.Errorf("Expected the placement phase without routing, got %d edges and %v", len(placed.Edges), report.Placement.Breakdown)
+	}
+
+	// The routing phase keeps the placement and connects every net without adding waypoints
+	for i, c := range placed.Components {
+		if r := routed.Components[i]; r.CX != c.CX || r.CY != c.CY || r.Rotation != c.Rotation {
+			t.Errorf("Component %d moved from %v, %v to %v, %v during the routing phase", i, c.CX, c.CY, r.CX, r.CY)
+		}
+	}
+
+	if len(routed.Components) != len(placed.Components) || report.Routing.Breakdown.Term("unconnected").Raw != 0 {
+		t.Errorf("Expected every net routed without waypoints, got %v", report.Routing.Breakdown)
+	}
+
+	if c := routed.Components[0]; c.CX != cx || c.CY != cy || c.Locked != pcb.LOCK_POSITION || routed.Components[1].Locked != 0 {
+		t.Errorf("Expected the locks of the components to be restored, got %v and %v", c.Locked, routed.Components[1].Locked)
+	}
+}
+
+func TestPipelineExplain(t *testing.T) {
+	templates := []pcb.Component{
+		{Nodes: []pcb.ComponentNode{{DX: -15}, {DX: 15}}, X1: -25, Y1: -10, X2: 25, Y2: 10},
+	}
+	p := pcb.GeneratePcbFull(templates, 4, 2, 300, 300, rand.New(rand.NewSource(1)))
+	pgo := pcb.NewPcbGeneticOperators(1, 0.5, 0.2, 300, 300, 4, 2, 10, pcb.MutationParams{GlobalMutationWeight: 1, RerouteEdgeMutationWeight: 1}, pcb.EvaluationParams{SamePlaneIntersectionCost: 1, HpwlCost: 1})
+
+	explained := 0
+	pl := pipeline.New(pipeline.Phase{Operators: pgo, Generations: 1}, pipeline.Phase{Operators: pgo, Generations: 1}, pipeline.Params{
+		PopulationSize: 10,
+		ElitarismKeepN: 2,
+		Explain:        true,
+		OnGeneration: func(phase string, stats genetic.Statistics[*pcb.Pcb], details fmt.Stringer) {
+			if b, ok := details.(*pcb.Breakdown); ok && math.Abs(b.Fitness-stats.Best.Fitness) < 1e-9 {
+				explained++
+			}
+		},
+	})
+	pl.Run(p)
+
+	if explained != 2 {
+		t.Errorf("Expected the best individual of both phases to be explained, got %d", explained)
+	}
+}
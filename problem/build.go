@@ -0,0 +1,433 @@
+package problem
+
+import (
+	"fmt"
+	"genetic_pcb/geo"
+	"genetic_pcb/pcb"
+	"math/rand"
+
+	"github.com/twpayne/go-geom"
+)
+
+// closedRing returns the flat coordinates of points, repeating the first one at the end if needed.
+func closedRing(points []Point) []float64 {
+	res := make([]float64, 0, 2*len(points)+2)
+
+	for _, pt := range points {
+		res = append(res, pt[0], pt[1])
+	}
+
+	if points[0] != points[len(points)-1] {
+		res = append(res, points[0][0], points[0][1])
+	}
+
+	return res
+}
+
+func (p *Problem) BuildBoard() *pcb.Board {
+	var board *pcb.Board
+
+	if p.Board.Outline == nil {
+		board = pcb.NewRectangularBoard(p.Board.Width, p.Board.Height)
+	} else {
+		flatCoords := closedRing(p.Board.Outline)
+		ends := []int{len(flatCoords)}
+
+		for _, c := range p.Board.Cutouts {
+			flatCoords = append(flatCoords, closedRing(c)...)
+			ends = append(ends, len(flatCoords))
+		}
+
+		board = pcb.NewBoard(geom.NewPolygonFlat(geom.XY, flatCoords, ends))
+	}
+
+	for _, k := range p.Board.Keepouts {
+		flatCoords := closedRing(k.Area)
+		keepout := pcb.Keepout{
+			Kind:  pcb.COMPONENT_KEEPOUT,
+			Plane: k.Plane,
+			Area:  geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)}),
+		}
+
+		if k.Kind == ROUTING_KEEPOUT {
+			keepout.Kind = pcb.ROUTING_KEEPOUT
+		}
+
+		board.Keepouts = append(board.Keepouts, keepout)
+	}
+
+	for _, l := range p.Board.Layers {
+		layer := pcb.Layer{Name: l.Name, RoutingCost: l.RoutingCost}
+
+		if len(l.AllowedNets) > 0 {
+			layer.AllowedNets = make([]int, 0, len(l.AllowedNets))
+
+			for _, name := range l.AllowedNets {
+				for i, n := range p.Nets {
+					if n.Name == name {
+						layer.AllowedNets = append(layer.AllowedNets, i)
+					}
+				}
+			}
+		}
+
+		if l.Color != "" {
+			layer.Color, _ = parseColor(l.Color)
+		}
+
+		board.Layers = append(board.Layers, layer)
+	}
+
+	for _, z := range p.Board.Zones {
+		zone := pcb.Zone{Plane: z.Plane, Clearance: z.Clearance}
+
+		for i, n := range p.Nets {
+			if n.Name == z.Net {
+				zone.Net = i
+			}
+		}
+
+		if z.Clearance == 0 {
+			zone.Clearance = p.EvaluationParams.MinDist
+		}
+
+		if z.Outline != nil {
+			flatCoords := closedRing(z.Outline)
+			zone.Outline = geom.NewPolygonFlat(geom.XY, flatCoords, []int{len(flatCoords)})
+		}
+
+		board.Zones = append(board.Zones, zone)
+	}
+
+	return board
+}
+
+// padRefs returns the "REF.PAD" reference of every node, in the order BuildPcb creates them.
+func (p *Problem) padRefs() []string {
+	refs := make([]string, 0)
+
+	for _, c := range p.Components {
+		for _, pad := range p.footprint(c.Footprint).Pads {
+			refs = append(refs, c.Ref+"."+pad.Name)
+		}
+	}
+
+	return refs
+}
+
+func (p *Problem) BuildPcb(randomGenerator *rand.Rand) (*pcb.Pcb, error) {
+	board := p.BuildBoard()
+	genome := &pcb.Genome{
+		Nodes:      make([]pcb.Node, 0),
+		Components: make([]pcb.Component, len(p.Components)),
+		Nets:       make([]pcb.Net, len(p.Nets)),
+	}
+
+	for i, ci := range p.Components {
+		f := p.footprint(ci.Footprint)
+
+		c := pcb.Component{
+			Nodes:  make([]pcb.ComponentNode, len(f.Pads)),
+			X1:     f.X1,
+			Y1:     f.Y1,
+			X2:     f.X2,
+			Y2:     f.Y2,
+			Anchor: boardEdges[ci.Anchor],
+
+			Rotations:          f.Rotations,
+			ContinuousRotation: f.ContinuousRotation,
+		}
+
+		if len(ci.Rotations) > 0 {
+			c.Rotations = ci.Rotations
+		}
+
+		for j, pad := range f.Pads {
+			c.Nodes[j] = pcb.ComponentNode{
+				Node:   len(genome.Nodes),
+				DX:     pad.DX,
+				DY:     pad.DY,
+				Type:   padTypes[pad.Type],
+				Shape:  padShapes[pad.Shape],
+				W:      pad.Width,
+				H:      pad.Height,
+				Radius: pad.Radius,
+			}
+			genome.Nodes = append(genome.Nodes, pcb.Node{X: pad.DX, Y: pad.DY, Component: i})
+		}
+
+		// Flip before locking, locked components can't be flipped
+		if boardSides[ci.Side] == pcb.BOTTOM_SIDE {
+			pcb.FlipComponent(&c, board)
+		}
+		c.Locked = lockKinds[ci.Locked]
+
+		if ci.Placement != nil {
+			c.CX, c.CY, c.Rotation = ci.Placement.X, ci.Placement.Y, ci.Placement.Rotation
+			pcb.AnchorToBoardEdge(&c, board)
+		} else {
+			pcb.RandomizeComponentRotation(&c, board, randomGenerator)
+			pcb.RandomizeComponentPosition(&c, board, randomGenerator)
+		}
+
+		pcb.PlaceComponentNodes(genome.Nodes, &c)
+		genome.Components[i] = c
+	}
+
+	nodeOf := make(map[string]int)
+	for i, ref := range p.padRefs() {
+		nodeOf[ref] = i
+	}
+
+	genome.NetClasses = p.buildNetClasses()
+	netOf := make(map[string]int, len(p.Nets))
+
+	for i, n := range p.Nets {
+		netOf[n.Name] = i
+		genome.Nets[i].Class = p.netClassIndex(n.Class)
+		for _, ref := range n.Pads {
+			genome.Nets[i].Nodes = append(genome.Nets[i].Nodes, nodeOf[ref])
+		}
+	}
+
+	for _, w := range p.Waypoints {
+		nodeOf[w.Name] = pcb.AddWaypoint(genome, netOf[w.Net], w.X, w.Y, nil)
+	}
+
+	res := pcb.NewPcb(genome)
+	routed := make([]int, len(p.Nets))
+
+	for _, r := range p.Routes {
+		net := netOf[r.Net]
+		genome.Edges = append(genome.Edges, pcb.Edge{From: nodeOf[r.From], To: nodeOf[r.To], Net: net, Plane: r.Plane, Bend: r.Bend})
+		routed[net]++
+	}
+
+	for i, n := range genome.Nets {
+		switch {
+		case board.HasZone(i):
+			// Zones connect their net, routes only have to be free of cycles
+			if !isAcyclic(genome.Edges, i, n.Nodes) {
+				return nil, fmt.Errorf("routes of net %q form a cycle", p.Nets[i].Name)
+			}
+		case routed[i] == 0:
+			pcb.GenerateNet(res, i, randomGenerator)
+		case routed[i] != len(n.Nodes)-1 || !isAcyclic(genome.Edges, i, n.Nodes):
+			return nil, fmt.Errorf("routes of net %q do not form a spanning tree of its pads and waypoints", p.Nets[i].Name)
+		}
+	}
+
+	pcb.SortPcbEdges(res)
+
+	return res, nil
+}
+
+// viaSize returns the via diameter of the rules, which defaults to the node size.
+func (p *Problem) viaSize() float64 {
+	if p.Rules.ViaSize > 0 {
+		return p.Rules.ViaSize
+	}
+
+	return p.Rules.NodeSize
+}
+
+// buildNetClasses returns the net classes of the genome, the first one being the default class
+// built from the rules, or nil if the problem has no net classes.
+func (p *Problem) buildNetClasses() []pcb.NetClass {
+	if len(p.NetClasses) == 0 {
+		return nil
+	}
+
+	res := make([]pcb.NetClass, 0, len(p.NetClasses)+1)
+	res = append(res, pcb.NetClass{
+		Name:      "default",
+		Width:     p.Rules.EdgeSize,
+		Clearance: p.EvaluationParams.MinDist,
+		ViaSz:     p.viaSize(),
+	})
+
+	for _, c := range p.NetClasses {
+		class := pcb.NetClass{Name: c.Name, Width: c.Width, Clearance: c.Clearance, ViaSz: c.ViaSize}
+		if class.ViaSz == 0 {
+			class.ViaSz = p.viaSize()
+		}
+
+		res = append(res, class)
+	}
+
+	return res
+}
+
+// netClassIndex returns the index in the genome net classes of the named class, 0 (the default
+// class) if name is empty.
+func (p *Problem) netClassIndex(name string) int {
+	for i, c := range p.NetClasses {
+		if c.Name == name {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// isAcyclic tells whether the edges of the net are free of cycles, in which case they span its
+// nodes if there is one less of them than nodes.
+func isAcyclic(edges []pcb.Edge, net int, nodes []int) bool {
+	parent := make(map[int]int, len(nodes))
+	for _, n := range nodes {
+		parent[n] = n
+	}
+
+	var find func(n int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+
+	for _, e := range edges {
+		if e.Net != net {
+			continue
+		}
+
+		r1, r2 := find(e.From), find(e.To)
+		if r1 == r2 {
+			return false
+		}
+		parent[r1] = r2
+	}
+
+	return true
+}
+
+// BuildOperators returns the genetic operators of the problem, failing if one of its cost terms
+// can't be built from its params.
+func (p *Problem) BuildOperators() (*pcb.PcbGeneticOperators, error) {
+	board := p.BuildBoard()
+
+	pgo := pcb.NewPcbGeneticOperators(
+		p.Genetic.FitnessExp,
+		p.Genetic.MutateProb,
+		p.Genetic.MutateSingleComponentProb,
+		board.Width(),
+		board.Height(),
+		p.Rules.NodeSize,
+		p.Rules.EdgeSize,
+		p.Genetic.LocalMutationMaxDelta,
+		p.MutationParams,
+		p.EvaluationParams,
+	)
+	pgo.SetBoard(board)
+	pgo.SetViaSize(p.viaSize())
+	pgo.SetRoutingStyle(routingStyles[p.Rules.RoutingStyle])
+
+	for _, t := range p.CostTerms {
+		term, err := pcb.NewCostTerm(t.Name, pgo, t.Weight, t.Params)
+		if err != nil {
+			return nil, fmt.Errorf("cost term %q: %w", t.Name, err)
+		}
+
+		pgo.AddCostTerm(term)
+	}
+
+	return pgo, nil
+}
+
+// BuildPhaseOperators returns the genetic operators of the problem with the parameters of phase.
+func (p *Problem) BuildPhaseOperators(phase Phase) (*pcb.PcbGeneticOperators, error) {
+	res := *p
+
+	if phase.Genetic != nil {
+		res.Genetic = *phase.Genetic
+	}
+
+	if phase.MutationParams != nil {
+		res.MutationParams = *phase.MutationParams
+	}
+
+	if phase.EvaluationParams != nil {
+		res.EvaluationParams = *phase.EvaluationParams
+	}
+
+	return res.BuildOperators()
+}
+
+// WithSolution returns a copy of the problem whose placements and routes are taken from s,
+// which must have been built from this problem.
+func (p *Problem) WithSolution(s *pcb.Pcb) (*Problem, error) {
+	refs := p.padRefs()
+	waypoints := s.Genome.Waypoints()
+
+	if len(s.Genome.Components)-len(waypoints) != len(p.Components) {
+		return nil, fmt.Errorf("solution has %d components, problem has %d", len(s.Genome.Components)-len(waypoints), len(p.Components))
+	}
+
+	if len(s.Genome.Nodes)-len(waypoints) != len(refs) {
+		return nil, fmt.Errorf("solution has %d nodes, problem has %d pads", len(s.Genome.Nodes)-len(waypoints), len(refs))
+	}
+
+	res := *p
+	res.Components = make([]Component, len(p.Components))
+	res.Waypoints = make([]Waypoint, len(waypoints))
+	res.Routes = make([]Route, len(s.Genome.Edges))
+
+	// Waypoints follow the pads, they are renamed in order
+	for i, n := range waypoints {
+		name := fmt.Sprintf("W%d", i+1)
+		refs = append(refs, name)
+		res.Waypoints[i] = Waypoint{Name: name, X: s.Genome.Nodes[n].X, Y: s.Genome.Nodes[n].Y}
+
+		for j, net := range s.Genome.Nets {
+			for _, nn := range net.Nodes {
+				if nn == n {
+					res.Waypoints[i].Net = p.Nets[j].Name
+				}
+			}
+		}
+	}
+
+	for i, c := range p.Components {
+		sc := s.Genome.Components[i]
+		c.Placement = &Placement{X: sc.CX, Y: sc.CY, Rotation: sc.Rotation}
+
+		c.Side = ""
+		if sc.Side == pcb.BOTTOM_SIDE {
+			c.Side = "bottom"
+		}
+		res.Components[i] = c
+	}
+
+	for i, e := range s.Genome.Edges {
+		res.Routes[i] = Route{Net: p.Nets[e.Net].Name, From: refs[e.From], To: refs[e.To], Plane: e.Plane, Bend: e.Bend}
+	}
+
+	return &res, nil
+}
+
+// WithTightBoard returns a copy of the problem whose board is a fixed one with the given outline,
+// usually the tight outline of a solution of an automatically sized board. Cutouts outside the
+// outline are dropped.
+func (p *Problem) WithTightBoard(outline *geom.Polygon) *Problem {
+	res := *p
+	res.Board.Width, res.Board.Height, res.Board.AutoSize = 0, 0, false
+	res.Board.Outline = make([]Point, 0, outline.NumCoords())
+	res.Board.Cutouts = make([][]Point, 0, len(p.Board.Cutouts))
+
+	for _, c := range outline.LinearRing(0).Coords() {
+		res.Board.Outline = append(res.Board.Outline, Point{c.X(), c.Y()})
+	}
+
+	for _, c := range p.Board.Cutouts {
+		inside := true
+		for _, pt := range c {
+			inside = inside && geo.IsPointInPolygon(geom.Coord{pt[0], pt[1]}, outline)
+		}
+
+		if inside {
+			res.Board.Cutouts = append(res.Board.Cutouts, c)
+		}
+	}
+
+	return &res
+}
@@ -0,0 +1,650 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"genetic_pcb/pcb"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+)
+
+// SchemaVersion is the version of the problem file format written by this package.
+const SchemaVersion = 1
+
+type Point [2]float64
+
+const (
+	COMPONENT_KEEPOUT = "component"
+	ROUTING_KEEPOUT   = "routing"
+)
+
+type Keepout struct {
+	// Kind is either COMPONENT_KEEPOUT or ROUTING_KEEPOUT
+	Kind string `json:"kind"`
+	// Plane is the plane where routing is forbidden, for routing keepouts
+	Plane int     `json:"plane,omitempty"`
+	Area  []Point `json:"area"`
+}
+
+type Layer struct {
+	Name        string  `json:"name"`
+	RoutingCost float64 `json:"routingCost"`
+	// AllowedNets are the names of the only nets that can be routed on the layer, all if empty
+	AllowedNets []string `json:"allowedNets,omitempty"`
+	// Color is a "#rrggbb" color used when rendering the layer
+	Color string `json:"color,omitempty"`
+}
+
+// Zone is a copper pour of a net on a plane, inside Outline or the whole board if it is empty.
+type Zone struct {
+	Net     string  `json:"net"`
+	Plane   int     `json:"plane,omitempty"`
+	Outline []Point `json:"outline,omitempty"`
+	// Clearance is the distance kept from the copper of other nets, the evaluation minimum distance if zero
+	Clearance float64 `json:"clearance,omitempty"`
+}
+
+// Board is a width x height rectangle unless Outline is given, in which case Width and Height are ignored.
+// Boards with no layers have two planes.
+type Board struct {
+	Width    float64   `json:"width,omitempty"`
+	Height   float64   `json:"height,omitempty"`
+	Outline  []Point   `json:"outline,omitempty"`
+	Cutouts  [][]Point `json:"cutouts,omitempty"`
+	Keepouts []Keepout `json:"keepouts,omitempty"`
+	Layers   []Layer   `json:"layers,omitempty"`
+	Zones    []Zone    `json:"zones,omitempty"`
+	// AutoSize makes the board only an upper limit, solutions get the tight outline of their
+	// components and traces. It needs a positive boardAreaCost evaluation parameter.
+	AutoSize bool `json:"autoSize,omitempty"`
+}
+
+func (b *Board) numPlanes() int {
+	if len(b.Layers) == 0 {
+		return pcb.DEFAULT_PLANES
+	}
+
+	return len(b.Layers)
+}
+
+func parseColor(s string) (color.Color, error) {
+	var r, g, b uint8
+
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil || len(s) != 7 {
+		return nil, fmt.Errorf("invalid color %q, expected #rrggbb", s)
+	}
+
+	return color.RGBA{r, g, b, 255}, nil
+}
+
+var padTypes = map[string]pcb.PadType{
+	"":    pcb.THROUGH_HOLE_PAD,
+	"th":  pcb.THROUGH_HOLE_PAD,
+	"smd": pcb.SMD_PAD,
+}
+
+var padShapes = map[string]pcb.PadShape{
+	"":          pcb.RECTANGLE_PAD,
+	"rect":      pcb.RECTANGLE_PAD,
+	"circle":    pcb.CIRCLE_PAD,
+	"roundrect": pcb.ROUNDED_RECTANGLE_PAD,
+	"oval":      pcb.OVAL_PAD,
+}
+
+var routingStyles = map[string]pcb.RoutingStyle{
+	"":           pcb.STRAIGHT_ROUTING,
+	"straight":   pcb.STRAIGHT_ROUTING,
+	"orthogonal": pcb.ORTHOGONAL_ROUTING,
+	"octilinear": pcb.OCTILINEAR_ROUTING,
+}
+
+var boardSides = map[string]pcb.BoardSide{
+	"":       pcb.TOP_SIDE,
+	"top":    pcb.TOP_SIDE,
+	"bottom": pcb.BOTTOM_SIDE,
+}
+
+type Pad struct {
+	Name string  `json:"name"`
+	DX   float64 `json:"dx"`
+	DY   float64 `json:"dy"`
+	// Type is either "th" (through-hole, the default) or "smd"
+	Type string `json:"type,omitempty"`
+	// Shape is "rect" (the default), "circle", "roundrect" or "oval"
+	Shape string `json:"shape,omitempty"`
+	// Width and Height are the pad size, a square of the rules node size if Width is zero.
+	// Circles have a diameter of Width.
+	Width  float64 `json:"width,omitempty"`
+	Height float64 `json:"height,omitempty"`
+	// Radius is the corner radius of "roundrect" pads
+	Radius float64 `json:"radius,omitempty"`
+}
+
+type Footprint struct {
+	Name string  `json:"name"`
+	Pads []Pad   `json:"pads"`
+	X1   float64 `json:"x1"`
+	Y1   float64 `json:"y1"`
+	X2   float64 `json:"x2"`
+	Y2   float64 `json:"y2"`
+	// Rotations are the allowed rotations in degrees, 0, 90, 180 and 270 if empty
+	Rotations          []float64 `json:"rotations,omitempty"`
+	ContinuousRotation bool      `json:"continuousRotation,omitempty"`
+}
+
+type Placement struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Rotation float64 `json:"rotation"`
+}
+
+var lockKinds = map[string]pcb.LockKind{
+	"":         0,
+	"position": pcb.LOCK_POSITION,
+	"rotation": pcb.LOCK_ROTATION,
+	"all":      pcb.LOCK_ALL,
+}
+
+var boardEdges = map[string]pcb.BoardEdge{
+	"":       pcb.NO_EDGE,
+	"left":   pcb.LEFT_EDGE,
+	"top":    pcb.TOP_EDGE,
+	"right":  pcb.RIGHT_EDGE,
+	"bottom": pcb.BOTTOM_EDGE,
+}
+
+type Component struct {
+	Ref       string     `json:"ref"`
+	Footprint string     `json:"footprint"`
+	Placement *Placement `json:"placement,omitempty"`
+	// Locked is one of "position", "rotation" or "all", locked components need a placement
+	Locked string `json:"locked,omitempty"`
+	// Anchor is one of "left", "top", "right" or "bottom", the component slides along that board side
+	Anchor string `json:"anchor,omitempty"`
+	// Rotations overrides the allowed rotations of the footprint
+	Rotations []float64 `json:"rotations,omitempty"`
+	// Side is either "top" (the default) or "bottom", pads are given as seen from the top
+	// and are mirrored for bottom side components
+	Side string `json:"side,omitempty"`
+}
+
+// Net lists the pads it connects as "REF.PAD" strings, e.g. "R1.1".
+type Net struct {
+	Name string   `json:"name"`
+	Pads []string `json:"pads"`
+	// Class is the name of the net class, nets without one follow the global rules
+	Class string `json:"class,omitempty"`
+}
+
+// NetClass overrides the rules for the nets assigned to it.
+type NetClass struct {
+	Name      string  `json:"name"`
+	Width     float64 `json:"width"`
+	Clearance float64 `json:"clearance"`
+	// ViaSize is the via diameter, the one of the rules if not given
+	ViaSize float64 `json:"viaSize,omitempty"`
+}
+
+// Waypoint is a point where the traces of a net bend or branch, routes reference it by name.
+type Waypoint struct {
+	Name string  `json:"name"`
+	Net  string  `json:"net"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Route connects two pads or waypoints of the net.
+type Route struct {
+	Net   string `json:"net"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Plane int    `json:"plane"`
+	// Bend chooses between the two paths of non straight routing styles
+	Bend bool `json:"bend,omitempty"`
+}
+
+type DesignRules struct {
+	NodeSize float64 `json:"nodeSize"`
+	EdgeSize float64 `json:"edgeSize"`
+	// ViaSize is the via diameter, nodeSize if not given
+	ViaSize float64 `json:"viaSize,omitempty"`
+	// RoutingStyle is "straight" (the default), "orthogonal" or "octilinear" (45 degrees)
+	RoutingStyle string `json:"routingStyle,omitempty"`
+}
+
+// CostTerm adds to the evaluation a term registered with pcb.RegisterCostTerm.
+type CostTerm struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	// Params is given as is to the factory of the term
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type GeneticParams struct {
+	FitnessExp                float64 `json:"fitnessExp"`
+	MutateProb                float64 `json:"mutateProb"`
+	MutateSingleComponentProb float64 `json:"mutateSingleComponentProb"`
+	LocalMutationMaxDelta     float64 `json:"localMutationMaxDelta"`
+}
+
+// Phase overrides the parameters of the problem for one phase of the two-phase optimization, the
+// ones not given are those of the problem.
+type Phase struct {
+	Generations      int                   `json:"generations"`
+	Genetic          *GeneticParams        `json:"genetic,omitempty"`
+	MutationParams   *pcb.MutationParams   `json:"mutationParams,omitempty"`
+	EvaluationParams *pcb.EvaluationParams `json:"evaluationParams,omitempty"`
+}
+
+// Phases configures the two-phase optimization, which places the components without routing them
+// first, then routes them with the placement frozen.
+type Phases struct {
+	Placement Phase `json:"placement"`
+	Routing   Phase `json:"routing"`
+}
+
+type Problem struct {
+	Version          int                  `json:"version"`
+	Board            Board                `json:"board"`
+	Footprints       []Footprint          `json:"footprints"`
+	Components       []Component          `json:"components"`
+	Nets             []Net                `json:"nets"`
+	NetClasses       []NetClass           `json:"netClasses,omitempty"`
+	Waypoints        []Waypoint           `json:"waypoints,omitempty"`
+	Routes           []Route              `json:"routes,omitempty"`
+	Rules            DesignRules          `json:"rules"`
+	Genetic          GeneticParams        `json:"genetic"`
+	MutationParams   pcb.MutationParams   `json:"mutationParams"`
+	EvaluationParams pcb.EvaluationParams `json:"evaluationParams"`
+	CostTerms        []CostTerm           `json:"costTerms,omitempty"`
+	Phases           *Phases              `json:"phases,omitempty"`
+}
+
+func Read(r io.Reader) (*Problem, error) {
+	var p Problem
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("decoding problem: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func Load(path string) (*Problem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+func (p *Problem) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(p)
+}
+
+func (p *Problem) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// splitPadRef splits a "REF.PAD" reference at its last dot.
+func splitPadRef(ref string) (string, string, error) {
+	i := strings.LastIndex(ref, ".")
+
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", fmt.Errorf("invalid pad reference %q, expected REF.PAD", ref)
+	}
+
+	return ref[:i], ref[i+1:], nil
+}
+
+func (p *Problem) footprint(name string) *Footprint {
+	for i := range p.Footprints {
+		if p.Footprints[i].Name == name {
+			return &p.Footprints[i]
+		}
+	}
+
+	return nil
+}
+
+func (p *Problem) Validate() error {
+	if p.Version != SchemaVersion {
+		return fmt.Errorf("unsupported problem version %d, expected %d", p.Version, SchemaVersion)
+	}
+
+	if err := p.Board.validate(); err != nil {
+		return err
+	}
+
+	if p.Rules.NodeSize <= 0 || p.Rules.EdgeSize <= 0 || p.Rules.ViaSize < 0 {
+		return fmt.Errorf("rules: nodeSize and edgeSize must be positive, viaSize can't be negative")
+	}
+
+	if _, ok := routingStyles[p.Rules.RoutingStyle]; !ok {
+		return fmt.Errorf("rules: unknown routing style %q", p.Rules.RoutingStyle)
+	}
+
+	footprints := make(map[string]bool, len(p.Footprints))
+
+	for _, f := range p.Footprints {
+		if footprints[f.Name] {
+			return fmt.Errorf("duplicate footprint %q", f.Name)
+		}
+		footprints[f.Name] = true
+
+		if len(f.Pads) == 0 {
+			return fmt.Errorf("footprint %q has no pads", f.Name)
+		}
+
+		if f.X1 >= f.X2 || f.Y1 >= f.Y2 {
+			return fmt.Errorf("footprint %q has an empty outline", f.Name)
+		}
+
+		if err := validateRotations(f.Rotations); err != nil {
+			return fmt.Errorf("footprint %q: %w", f.Name, err)
+		}
+
+		pads := make(map[string]bool, len(f.Pads))
+		for _, pad := range f.Pads {
+			if pad.Name == "" || pads[pad.Name] {
+				return fmt.Errorf("footprint %q has a missing or duplicate pad name %q", f.Name, pad.Name)
+			}
+			pads[pad.Name] = true
+
+			if _, ok := padTypes[pad.Type]; !ok {
+				return fmt.Errorf("footprint %q: pad %q has unknown type %q", f.Name, pad.Name, pad.Type)
+			}
+
+			if err := validatePadShape(pad); err != nil {
+				return fmt.Errorf("footprint %q: pad %q %w", f.Name, pad.Name, err)
+			}
+		}
+	}
+
+	if len(p.Components) == 0 {
+		return fmt.Errorf("problem has no components")
+	}
+
+	refs := make(map[string]*Footprint, len(p.Components))
+
+	for _, c := range p.Components {
+		if c.Ref == "" || refs[c.Ref] != nil {
+			return fmt.Errorf("missing or duplicate component ref %q", c.Ref)
+		}
+
+		f := p.footprint(c.Footprint)
+		if f == nil {
+			return fmt.Errorf("component %q uses unknown footprint %q", c.Ref, c.Footprint)
+		}
+		refs[c.Ref] = f
+
+		if _, ok := lockKinds[c.Locked]; !ok {
+			return fmt.Errorf("component %q has unknown lock %q", c.Ref, c.Locked)
+		}
+
+		if c.Locked != "" && c.Placement == nil {
+			return fmt.Errorf("component %q is locked but has no placement", c.Ref)
+		}
+
+		if err := validateRotations(c.Rotations); err != nil {
+			return fmt.Errorf("component %q: %w", c.Ref, err)
+		}
+
+		if _, ok := boardSides[c.Side]; !ok {
+			return fmt.Errorf("component %q has unknown side %q", c.Ref, c.Side)
+		}
+
+		if _, ok := boardEdges[c.Anchor]; !ok {
+			return fmt.Errorf("component %q has unknown anchor %q", c.Ref, c.Anchor)
+		}
+	}
+
+	if len(p.Nets) == 0 {
+		return fmt.Errorf("problem has no nets")
+	}
+
+	classes := make(map[string]bool, len(p.NetClasses))
+
+	for _, c := range p.NetClasses {
+		if c.Name == "" || classes[c.Name] {
+			return fmt.Errorf("missing or duplicate net class name %q", c.Name)
+		}
+		classes[c.Name] = true
+
+		if c.Width <= 0 || c.Clearance < 0 || c.ViaSize < 0 {
+			return fmt.Errorf("net class %q: width must be positive, clearance and viaSize can't be negative", c.Name)
+		}
+	}
+
+	usedPads := make(map[string]string)
+	nets := make(map[string]bool, len(p.Nets))
+
+	for _, n := range p.Nets {
+		if n.Name == "" || nets[n.Name] {
+			return fmt.Errorf("missing or duplicate net name %q", n.Name)
+		}
+		nets[n.Name] = true
+
+		if n.Class != "" && !classes[n.Class] {
+			return fmt.Errorf("net %q has unknown class %q", n.Name, n.Class)
+		}
+
+		if len(n.Pads) == 0 {
+			return fmt.Errorf("net %q has no pads", n.Name)
+		}
+
+		for _, ref := range n.Pads {
+			if err := p.validatePadRef(refs, ref); err != nil {
+				return fmt.Errorf("net %q: %w", n.Name, err)
+			}
+
+			if other, ok := usedPads[ref]; ok {
+				return fmt.Errorf("pad %q is in both net %q and net %q", ref, other, n.Name)
+			}
+			usedPads[ref] = n.Name
+		}
+	}
+
+	// Waypoint names have no dot, so that they can't be mistaken for pads
+	for _, w := range p.Waypoints {
+		if w.Name == "" || strings.Contains(w.Name, ".") || usedPads[w.Name] != "" {
+			return fmt.Errorf("invalid or duplicate waypoint name %q", w.Name)
+		}
+
+		if !nets[w.Net] {
+			return fmt.Errorf("waypoint %q references unknown net %q", w.Name, w.Net)
+		}
+		usedPads[w.Name] = w.Net
+	}
+
+	for _, r := range p.Routes {
+		if !nets[r.Net] {
+			return fmt.Errorf("route references unknown net %q", r.Net)
+		}
+
+		for _, ref := range []string{r.From, r.To} {
+			if usedPads[ref] != r.Net {
+				return fmt.Errorf("route endpoint %q is not a pad or waypoint of net %q", ref, r.Net)
+			}
+		}
+
+		if r.Plane < 0 || r.Plane >= p.Board.numPlanes() {
+			return fmt.Errorf("route %s-%s is on plane %d, the board has %d", r.From, r.To, r.Plane, p.Board.numPlanes())
+		}
+	}
+
+	for _, l := range p.Board.Layers {
+		for _, n := range l.AllowedNets {
+			if !nets[n] {
+				return fmt.Errorf("layer %q allows unknown net %q", l.Name, n)
+			}
+		}
+	}
+
+	for i, z := range p.Board.Zones {
+		if !nets[z.Net] {
+			return fmt.Errorf("zone %d references unknown net %q", i, z.Net)
+		}
+	}
+
+	if p.Board.AutoSize && p.EvaluationParams.BoardAreaCost <= 0 {
+		return fmt.Errorf("automatically sized boards need a positive boardAreaCost")
+	}
+
+	terms := make(map[string]bool, len(p.CostTerms))
+
+	for _, t := range p.CostTerms {
+		if !pcb.IsCostTermRegistered(t.Name) || terms[t.Name] {
+			return fmt.Errorf("unknown or duplicate cost term %q, registered terms are %v", t.Name, pcb.RegisteredCostTerms())
+		}
+		terms[t.Name] = true
+
+		if t.Weight < 0 {
+			return fmt.Errorf("cost term %q has a negative weight", t.Name)
+		}
+	}
+
+	if p.Phases != nil && (p.Phases.Placement.Generations < 0 || p.Phases.Routing.Generations < 0) {
+		return fmt.Errorf("phases can't have a negative number of generations")
+	}
+
+	return nil
+}
+
+func (p *Problem) validatePadRef(refs map[string]*Footprint, ref string) error {
+	componentRef, padName, err := splitPadRef(ref)
+	if err != nil {
+		return err
+	}
+
+	f, ok := refs[componentRef]
+	if !ok {
+		return fmt.Errorf("pad %q references unknown component %q", ref, componentRef)
+	}
+
+	for _, pad := range f.Pads {
+		if pad.Name == padName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("footprint %q has no pad %q", f.Name, padName)
+}
+
+func (b *Board) validate() error {
+	for i, k := range b.Keepouts {
+		if k.Kind != COMPONENT_KEEPOUT && k.Kind != ROUTING_KEEPOUT {
+			return fmt.Errorf("keepout %d has unknown kind %q", i, k.Kind)
+		}
+
+		if len(k.Area) < 3 {
+			return fmt.Errorf("keepout %d needs at least 3 points, got %d", i, len(k.Area))
+		}
+
+		if k.Plane < 0 || k.Plane >= b.numPlanes() {
+			return fmt.Errorf("keepout %d is on plane %d, the board has %d", i, k.Plane, b.numPlanes())
+		}
+	}
+
+	for i, z := range b.Zones {
+		if z.Outline != nil && len(z.Outline) < 3 {
+			return fmt.Errorf("zone %d needs at least 3 points, got %d", i, len(z.Outline))
+		}
+
+		if z.Plane < 0 || z.Plane >= b.numPlanes() {
+			return fmt.Errorf("zone %d is on plane %d, the board has %d", i, z.Plane, b.numPlanes())
+		}
+
+		if z.Clearance < 0 {
+			return fmt.Errorf("zone %d has a negative clearance", i)
+		}
+	}
+
+	for _, l := range b.Layers {
+		if l.Color == "" {
+			continue
+		}
+
+		if _, err := parseColor(l.Color); err != nil {
+			return fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+	}
+
+	if b.Outline == nil {
+		if b.Width <= 0 || b.Height <= 0 {
+			return fmt.Errorf("board size must be positive, got %vx%v", b.Width, b.Height)
+		}
+
+		if len(b.Cutouts) > 0 {
+			return fmt.Errorf("board cutouts require an outline")
+		}
+
+		return nil
+	}
+
+	if len(b.Outline) < 3 {
+		return fmt.Errorf("board outline needs at least 3 points, got %d", len(b.Outline))
+	}
+
+	for i, c := range b.Cutouts {
+		if len(c) < 3 {
+			return fmt.Errorf("board cutout %d needs at least 3 points, got %d", i, len(c))
+		}
+	}
+
+	return nil
+}
+
+func validateRotations(rotations []float64) error {
+	for _, r := range rotations {
+		if r < 0 || r >= 360 {
+			return fmt.Errorf("rotation %v is not in [0, 360)", r)
+		}
+	}
+
+	return nil
+}
+
+func validatePadShape(pad Pad) error {
+	if _, ok := padShapes[pad.Shape]; !ok {
+		return fmt.Errorf("has unknown shape %q", pad.Shape)
+	}
+
+	if pad.Width < 0 || pad.Height < 0 || pad.Radius < 0 {
+		return fmt.Errorf("has a negative size")
+	}
+
+	if pad.Width == 0 && (pad.Height > 0 || pad.Radius > 0) {
+		return fmt.Errorf("has a size but no width")
+	}
+
+	if pad.Width > 0 && pad.Height == 0 && pad.Shape != "circle" {
+		return fmt.Errorf("has a width but no height")
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package problem_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"genetic_pcb/genetic"
+	"genetic_pcb/pcb"
+	"genetic_pcb/problem"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const exampleProblem = "../resource/problems/example.json"
+
+func TestLoadExample(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res, err := p.BuildPcb(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.Genome.Components) != 6 || len(res.Genome.Nodes) != 14 || len(res.Genome.Nets) != 5 {
+		t.Errorf("Unexpected genome size: %d components, %d nodes, %d nets", len(res.Genome.Components), len(res.Genome.Nodes), len(res.Genome.Nets))
+	}
+
+	// Every net of n pads is routed with n-1 edges
+	if len(res.Genome.Edges) != 14-5 {
+		t.Errorf("Expected %d edges, got %d", 14-5, len(res.Genome.Edges))
+	}
+
+	// The power nets use the power class, following the default one built from the rules
+	if class := res.Genome.NetClass(0); class == nil || class.Name != "power" || class.Width != 8 {
+		t.Errorf("Expected VCC to be in the power class, got %v", class)
+	}
+
+	if class := res.Genome.NetClass(2); class == nil || class.Name != "default" || class.Width != 5 {
+		t.Errorf("Expected B1 to be in the default class, got %v", class)
+	}
+
+	if pad := res.Genome.ComponentNode(0); pad == nil || pad.Shape != pcb.OVAL_PAD || pad.W != 8 || pad.H != 12 {
+		t.Errorf("Expected the first pad to be an 8x12 oval, got %v", pad)
+	}
+}
+
+func TestSolutionRoundTrip(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	original, _ := p.BuildPcb(rand.New(rand.NewSource(1)))
+
+	solved, err := p.WithSolution(original)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := solved.Write(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reloaded, err := problem.Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res, err := reloaded.BuildPcb(rand.New(rand.NewSource(2)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i, c := range res.Genome.Components {
+		o := original.Genome.Components[i]
+		if c.CX != o.CX || c.CY != o.CY || c.Rotation != o.Rotation {
+			t.Errorf("Component %d moved from %v,%v to %v,%v", i, o.CX, o.CY, c.CX, c.CY)
+		}
+	}
+
+	for i, e := range res.Genome.Edges {
+		if e != original.Genome.Edges[i] {
+			t.Errorf("Edge %d changed from %v to %v", i, original.Genome.Edges[i], e)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]string{
+		"version":   `{"version": 2}`,
+		"footprint": `{"version": 1, "board": {"width": 10, "height": 10}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [], "components": [{"ref": "R1", "footprint": "r"}]}`,
+		"unknown":   `{"version": 1, "unknownField": 1}`,
+		"netClass":  `{"version": 1, "board": {"width": 10, "height": 10}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1"}]}], "components": [{"ref": "R1", "footprint": "r"}], "nets": [{"name": "N", "pads": ["R1.1"], "class": "power"}]}`,
+		"padShape":  `{"version": 1, "board": {"width": 10, "height": 10}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1", "shape": "star", "width": 1}]}], "components": [{"ref": "R1", "footprint": "r"}]}`,
+		"costTerm":  `{"version": 1, "board": {"width": 10, "height": 10}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1"}]}], "components": [{"ref": "R1", "footprint": "r"}], "nets": [{"name": "N", "pads": ["R1.1"]}], "costTerms": [{"name": "unregistered", "weight": 1}]}`,
+		"autoSize":  `{"version": 1, "board": {"width": 10, "height": 10, "autoSize": true}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1"}]}], "components": [{"ref": "R1", "footprint": "r"}], "nets": [{"name": "N", "pads": ["R1.1"]}]}`,
+		"phases":    `{"version": 1, "board": {"width": 10, "height": 10}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1"}]}], "components": [{"ref": "R1", "footprint": "r"}], "nets": [{"name": "N", "pads": ["R1.1"]}], "phases": {"routing": {"generations": -1}}}`,
+		"zone":      `{"version": 1, "board": {"width": 10, "height": 10, "zones": [{"net": "GND", "plane": 1}]}, "rules": {"nodeSize": 1, "edgeSize": 1}, "footprints": [{"name": "r", "pads": [{"name": "1"}]}], "components": [{"ref": "R1", "footprint": "r"}], "nets": [{"name": "N", "pads": ["R1.1"]}]}`,
+	}
+
+	for name, src := range cases {
+		if _, err := problem.Read(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWaypointRoundTrip(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	original, _ := p.BuildPcb(rand.New(rand.NewSource(1)))
+	g := original.Genome
+
+	// Bend the first edge through a waypoint
+	e := g.Edges[0]
+	w := pcb.AddWaypoint(g, e.Net, 250, 250, nil)
+	g.Edges[0].To = w
+	g.Edges = append(g.Edges, pcb.Edge{From: w, To: e.To, Net: e.Net, Plane: e.Plane})
+
+	solved, err := p.WithSolution(original)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(solved.Waypoints) != 1 || solved.Waypoints[0].Net != p.Nets[e.Net].Name {
+		t.Fatalf("Unexpected waypoints %v", solved.Waypoints)
+	}
+
+	buf := bytes.Buffer{}
+	if err := solved.Write(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	reloaded, err := problem.Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res, err := reloaded.BuildPcb(rand.New(rand.NewSource(2)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	waypoints := res.Genome.Waypoints()
+	if len(waypoints) != 1 || res.Genome.Nodes[waypoints[0]].X != 250 || len(res.Genome.Edges) != len(g.Edges) {
+		t.Errorf("Waypoint was not restored: %v, %d edges", waypoints, len(res.Genome.Edges))
+	}
+}
+
+// scaledLengthTerm charges the length of the edges times a factor read from its params
+type scaledLengthTerm struct {
+	weight float64
+	Factor float64 `json:"factor"`
+}
+
+func (t *scaledLengthTerm) Name() string {
+	return "scaled length"
+}
+
+func (t *scaledLengthTerm) Weight() float64 {
+	return t.weight
+}
+
+func (t *scaledLengthTerm) Evaluate(p *pcb.Pcb) float64 {
+	return t.Factor * pcb.GetTotalPcbLength(p)
+}
+
+func init() {
+	pcb.RegisterCostTerm("scaled length", func(pgo *pcb.PcbGeneticOperators, weight float64, params json.RawMessage) (pcb.CostTerm, error) {
+		res := &scaledLengthTerm{weight: weight}
+		if err := json.Unmarshal(params, res); err != nil {
+			return nil, err
+		}
+
+		if res.Factor <= 0 {
+			return nil, fmt.Errorf("factor must be positive")
+		}
+
+		return res, nil
+	})
+}
+
+func TestCostTerms(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	p.CostTerms = []problem.CostTerm{{Name: "scaled length", Weight: 2, Params: json.RawMessage(`{"factor": 0.5}`)}}
+
+	pgo, err := p.BuildOperators()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	terms := pgo.CostTerms()
+	if term, ok := terms[len(terms)-1].(*scaledLengthTerm); !ok || term.Weight() != 2 || term.Factor != 0.5 {
+		t.Errorf("Expected the scaled length term last, got %v", terms)
+	}
+
+	p.CostTerms[0].Params = json.RawMessage(`{"factor": -1}`)
+	if _, err := p.BuildOperators(); err == nil {
+		t.Errorf("Expected an error for a negative factor")
+	}
+}
+
+func TestTightBoard(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	p.Board.AutoSize = true
+	p.EvaluationParams.BoardAreaCost = 1
+
+	pgo, err := p.BuildOperators()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	s, _ := p.BuildPcb(rand.New(rand.NewSource(1)))
+	pgo.Grow(s, genetic.NewGeneticContext())
+	outline := pgo.TightOutline(s)
+
+	solved, err := p.WithSolution(s)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	solved = solved.WithTightBoard(outline)
+
+	// The solution must be a valid fixed size problem on the tight outline
+	buf := bytes.Buffer{}
+	if err := solved.Write(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	read, err := problem.Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if read.Board.AutoSize || math.Abs(read.BuildBoard().Outline.Area()-outline.Area()) > 1e-9 {
+		t.Errorf("Expected a fixed board of area %v, got %+v", outline.Area(), read.Board)
+	}
+}
+
+func TestPhaseOperators(t *testing.T) {
+	p, err := problem.Load(exampleProblem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	placement := p.EvaluationParams
+	placement.HpwlCost = 1
+	p.Phases = &problem.Phases{Placement: problem.Phase{Generations: 10, EvaluationParams: &placement}}
+
+	pgo, err := p.BuildPhaseOperators(p.Phases.Placement)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if pgo.EvaluationParams().HpwlCost != 1 || pgo.GeometryParams().Board == nil {
+		t.Errorf("Expected the placement evaluation params on the problem board, got %+v", pgo.EvaluationParams())
+	}
+
+	// Parameters not given by the phase are the ones of the problem
+	pgo, err = p.BuildPhaseOperators(p.Phases.Routing)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if pgo.EvaluationParams() != p.EvaluationParams {
+		t.Errorf("Expected the problem evaluation params, got %+v", pgo.EvaluationParams())
+	}
+}
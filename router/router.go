@@ -0,0 +1,543 @@
+package router
+
+import (
+	"container/heap"
+	"genetic_pcb/geo"
+	"genetic_pcb/pcb"
+	"math"
+	"sort"
+
+	"github.com/twpayne/go-geom"
+)
+
+type Params struct {
+	// CellSize is the grid pitch, the edge size plus the minimum distance if zero
+	CellSize float64
+	// ViaCost is the path length a layer change is worth, 5 cells if zero
+	ViaCost float64
+}
+
+// Router reroutes edges of a placed pcb on a grid with one layer per board plane, using A* and
+// turning the corners and layer changes of the paths it finds into waypoints.
+type Router struct {
+	pgo    *pcb.PcbGeneticOperators
+	params Params
+}
+
+// Result counts the edges the router tried to reroute and the ones it couldn't, which are left as they were.
+type Result struct {
+	Rerouted int
+	Failed   int
+}
+
+func New(pgo *pcb.PcbGeneticOperators, params Params) *Router {
+	if params.CellSize <= 0 {
+		params.CellSize = pgo.GeometryParams().EdgeSz + pgo.EvaluationParams().MinDist
+	}
+
+	if params.ViaCost <= 0 {
+		params.ViaCost = 5 * params.CellSize
+	}
+
+	return &Router{pgo: pgo, params: params}
+}
+
+// Route rips up the edges involved in clearance, short, crossing and keepout violations and reroutes them.
+func (r *Router) Route(p *pcb.Pcb) Result {
+	p.ComputeGeometryWithParams(r.pgo.GeometryParams())
+	ripped := make(map[int]bool)
+
+	for _, v := range r.pgo.CheckDesignRules(p) {
+		switch v.Kind {
+		case pcb.CLEARANCE_VIOLATION, pcb.SHORT_VIOLATION, pcb.CROSSING_VIOLATION, pcb.KEEPOUT_VIOLATION:
+			for _, o := range v.Objects {
+				if o.Kind == pcb.EDGE_OBJECT {
+					ripped[o.Index] = true
+				}
+			}
+		}
+	}
+
+	return r.reroute(p, ripped)
+}
+
+// RouteAll rips up and reroutes every edge, keeping the placement as it is.
+func (r *Router) RouteAll(p *pcb.Pcb) Result {
+	ripped := make(map[int]bool, len(p.Genome.Edges))
+
+	for i := range p.Genome.Edges {
+		ripped[i] = true
+	}
+
+	return r.reroute(p, ripped)
+}
+
+func edgeLength(g *pcb.Genome, e pcb.Edge) float64 {
+	return math.Hypot(g.Nodes[e.From].X-g.Nodes[e.To].X, g.Nodes[e.From].Y-g.Nodes[e.To].Y)
+}
+
+func (r *Router) reroute(p *pcb.Pcb, ripped map[int]bool) Result {
+	g := p.Genome
+	res := Result{}
+	toRoute := make([]pcb.Edge, 0, len(ripped))
+	kept := make([]pcb.Edge, 0, len(g.Edges))
+
+	for i, e := range g.Edges {
+		if ripped[i] {
+			toRoute = append(toRoute, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+
+	g.Edges = kept
+
+	// Short edges first, they have less room to go around obstacles
+	sort.SliceStable(toRoute, func(i, j int) bool {
+		return edgeLength(g, toRoute[i]) < edgeLength(g, toRoute[j])
+	})
+
+	for _, e := range toRoute {
+		res.Rerouted++
+
+		if !r.routeEdge(p, e) {
+			res.Failed++
+			g.Edges = append(g.Edges, e)
+		}
+	}
+
+	pcb.SortPcbEdges(p)
+	p.ComputeGeometryWithParams(r.pgo.GeometryParams())
+
+	return res
+}
+
+// routeEdge connects the ends of e with a path on the grid avoiding the rest of the pcb, returning
+// false if there is none.
+func (r *Router) routeEdge(p *pcb.Pcb, e pcb.Edge) bool {
+	p.ComputeGeometryWithParams(r.pgo.GeometryParams())
+	gr := r.buildGrid(p, e.Net, p.Genome.Nodes[e.From])
+
+	start := gr.cellOf(p.Genome.Nodes[e.From].X, p.Genome.Nodes[e.From].Y)
+	goal := gr.cellOf(p.Genome.Nodes[e.To].X, p.Genome.Nodes[e.To].Y)
+
+	// Orthogonal edges turn diagonal steps into doglegs the grid knows nothing about
+	diagonal := r.pgo.GeometryParams().Style != pcb.ORTHOGONAL_ROUTING
+
+	path := gr.search(start, goal, r.endLayers(p, e.From, e.Net), r.endLayers(p, e.To, e.Net), r.params.ViaCost/r.params.CellSize, diagonal)
+	if path == nil {
+		return false
+	}
+
+	r.addPath(p.Genome, e, gr, path)
+
+	return true
+}
+
+// endLayers returns the layers a path can start or end on at node: the plane of SMD pads, any allowed plane otherwise.
+func (r *Router) endLayers(p *pcb.Pcb, node, net int) []int {
+	planes := r.pgo.GeometryParams().Planes
+
+	if plane, smd := p.Genome.PadPlane(node, planes); smd {
+		return []int{plane}
+	}
+
+	return r.pgo.Board().AllowedPlanes(net)
+}
+
+type vertex struct {
+	x, y  float64
+	layer int
+}
+
+// addPath adds to g the edges and waypoints following path, which replace e. The path starts and
+// ends in the exact positions of the ends of e, straight runs of the path become single edges and
+// layer changes become waypoints, where vias are placed.
+func (r *Router) addPath(g *pcb.Genome, e pcb.Edge, gr *grid, path []state) {
+	from, to := g.Nodes[e.From], g.Nodes[e.To]
+	first, last := path[0].cell, path[len(path)-1].cell
+
+	// Points of the path, each with the layer of the segment reaching it
+	points := []vertex{{from.X, from.Y, path[0].layer}}
+
+	for _, s := range path {
+		if s.cell == first || s.cell == last {
+			continue
+		}
+
+		x, y := gr.position(s.cell)
+		points = append(points, vertex{x, y, s.layer})
+	}
+
+	points = append(points, vertex{to.X, to.Y, path[len(path)-1].layer})
+	points = simplifyPath(points)
+
+	prev := e.From
+
+	for i := 1; i < len(points); i++ {
+		next := e.To
+		if i < len(points)-1 {
+			next = pcb.AddWaypoint(g, e.Net, points[i].x, points[i].y, nil)
+		}
+
+		g.Edges = append(g.Edges, pcb.Edge{From: prev, To: next, Net: e.Net, Plane: points[i].layer})
+		prev = next
+	}
+}
+
+// simplifyPath drops zero length segments and the points in the middle of straight runs on the same
+// layer. A layer change keeps the point where it happens, the segments after it carry the new layer.
+func simplifyPath(points []vertex) []vertex {
+	res := []vertex{points[0]}
+
+	for _, pt := range points[1:] {
+		last := res[len(res)-1]
+
+		if pt.x == last.x && pt.y == last.y {
+			continue
+		}
+
+		if len(res) > 1 && pt.layer == last.layer {
+			before := res[len(res)-2]
+			cross := (last.x-before.x)*(pt.y-last.y) - (last.y-before.y)*(pt.x-last.x)
+			dot := (last.x-before.x)*(pt.x-last.x) + (last.y-before.y)*(pt.y-last.y)
+
+			if math.Abs(cross) < 1e-9 && dot > 0 {
+				res[len(res)-1] = pt
+				continue
+			}
+		}
+
+		res = append(res, pt)
+	}
+
+	return res
+}
+
+// buildGrid marks the cells where an edge of the net can't pass on every layer: outside the board,
+// in routing keepouts and too close to objects of other nets. The lattice goes through origin, so
+// that paths leave it straight.
+func (r *Router) buildGrid(p *pcb.Pcb, net int, origin pcb.Node) *grid {
+	board := r.pgo.Board()
+	bounds := board.Bounds()
+	planes := r.pgo.GeometryParams().Planes
+	cell := r.params.CellSize
+	minX := origin.X - math.Floor((origin.X-bounds.Min(0))/cell)*cell
+	minY := origin.Y - math.Floor((origin.Y-bounds.Min(1))/cell)*cell
+	gr := newGrid(minX, minY, bounds.Max(0)-minX, bounds.Max(1)-minY, cell, planes)
+	g := p.Genome
+	halfWidth := g.EdgeWidth(net, r.pgo.GeometryParams().EdgeSz) / 2
+
+	for c := 0; c < gr.cells(); c++ {
+		x, y := gr.position(c)
+		if !board.ContainsPoint(x, y) {
+			gr.blockAll(c)
+		}
+	}
+
+	for layer := 0; layer < planes; layer++ {
+		if !board.IsNetAllowed(layer, net) {
+			for c := 0; c < gr.cells(); c++ {
+				gr.block(c, layer)
+			}
+		}
+	}
+
+	for _, k := range board.Keepouts {
+		if k.Kind == pcb.ROUTING_KEEPOUT && k.Plane < planes {
+			gr.blockPolygon(k.Area, halfWidth, []int{k.Plane})
+		}
+	}
+
+	nodeNets := make([]int, len(g.Nodes))
+	for i := range nodeNets {
+		nodeNets[i] = -1
+	}
+
+	for i, n := range g.Nets {
+		for _, node := range n.Nodes {
+			nodeNets[node] = i
+		}
+	}
+
+	nodePlanes := g.NodePlanes(planes)
+
+	allLayers := make([]int, planes)
+	for i := range allLayers {
+		allLayers[i] = i
+	}
+
+	for i, poly := range p.Geometry.Nodes {
+		if nodeNets[i] == net {
+			continue
+		}
+
+		layers := make([]int, 0, planes)
+		for l, on := range nodePlanes[i] {
+			if on {
+				layers = append(layers, l)
+			}
+		}
+
+		gr.blockPolygon(poly, halfWidth+r.pgo.Clearance(g, net, nodeNets[i]), layers)
+	}
+
+	for i, poly := range p.Geometry.Edges {
+		if other := g.Edges[i]; other.Net != net {
+			gr.blockPolygon(poly, halfWidth+r.pgo.Clearance(g, net, other.Net), []int{other.Plane})
+		}
+	}
+
+	for i, poly := range p.Geometry.Vias {
+		if site := p.Geometry.ViaSites[i]; site.Net != net {
+			gr.blockPolygon(poly, halfWidth+r.pgo.Clearance(g, net, site.Net), allLayers)
+		}
+	}
+
+	// Vias of the net cross every layer, they need the room of its class from the pads and the
+	// edges and vias of other nets
+	viaRadius := g.ViaSize(net, r.pgo.GeometryParams().ViaSz) / 2
+	forbidVias := func(poly *geom.Polygon, otherNet int) {
+		gr.cellsNear(poly, viaRadius+r.pgo.Clearance(g, net, otherNet), func(c int) {
+			gr.noVia[c] = true
+		})
+	}
+
+	for i, poly := range p.Geometry.Nodes {
+		forbidVias(poly, nodeNets[i])
+	}
+
+	for i, poly := range p.Geometry.Edges {
+		if other := g.Edges[i]; other.Net != net {
+			forbidVias(poly, other.Net)
+		}
+	}
+
+	for i, poly := range p.Geometry.Vias {
+		if site := p.Geometry.ViaSites[i]; site.Net != net {
+			forbidVias(poly, site.Net)
+		}
+	}
+
+	return gr
+}
+
+// grid is a lattice of points covering the board, with a blocked flag for each layer.
+type grid struct {
+	minX, minY float64
+	cellSize   float64
+	nx, ny     int
+	layers     int
+	blocked    []bool
+	noVia      []bool
+}
+
+func newGrid(minX, minY, width, height, cellSize float64, layers int) *grid {
+	nx, ny := int(width/cellSize)+1, int(height/cellSize)+1
+
+	return &grid{
+		minX:     minX,
+		minY:     minY,
+		cellSize: cellSize,
+		nx:       nx,
+		ny:       ny,
+		layers:   layers,
+		blocked:  make([]bool, nx*ny*layers),
+		noVia:    make([]bool, nx*ny),
+	}
+}
+
+// cells returns the number of cells of a layer, their indices are the same on every layer.
+func (gr *grid) cells() int {
+	return gr.nx * gr.ny
+}
+
+func (gr *grid) position(c int) (float64, float64) {
+	return gr.minX + float64(c%gr.nx)*gr.cellSize, gr.minY + float64(c/gr.nx)*gr.cellSize
+}
+
+func (gr *grid) cellOf(x, y float64) int {
+	i := int(math.Round((x - gr.minX) / gr.cellSize))
+	j := int(math.Round((y - gr.minY) / gr.cellSize))
+	i = int(math.Max(0, math.Min(float64(gr.nx-1), float64(i))))
+	j = int(math.Max(0, math.Min(float64(gr.ny-1), float64(j))))
+
+	return j*gr.nx + i
+}
+
+func (gr *grid) block(c, layer int) {
+	gr.blocked[layer*gr.nx*gr.ny+c] = true
+}
+
+func (gr *grid) blockAll(c int) {
+	for l := 0; l < gr.layers; l++ {
+		gr.block(c, l)
+	}
+}
+
+func (gr *grid) isBlocked(c, layer int) bool {
+	return gr.blocked[layer*gr.nx*gr.ny+c]
+}
+
+// cellsNear calls fn with every cell closer than margin to poly.
+func (gr *grid) cellsNear(poly *geom.Polygon, margin float64, fn func(c int)) {
+	b := poly.Bounds()
+	i1, j1 := int(math.Floor((b.Min(0)-margin-gr.minX)/gr.cellSize)), int(math.Floor((b.Min(1)-margin-gr.minY)/gr.cellSize))
+	i2, j2 := int(math.Ceil((b.Max(0)+margin-gr.minX)/gr.cellSize)), int(math.Ceil((b.Max(1)+margin-gr.minY)/gr.cellSize))
+
+	i1, j1 = int(math.Max(float64(i1), 0)), int(math.Max(float64(j1), 0))
+	i2, j2 = int(math.Min(float64(i2), float64(gr.nx-1))), int(math.Min(float64(j2), float64(gr.ny-1)))
+
+	for j := j1; j <= j2; j++ {
+		for i := i1; i <= i2; i++ {
+			c := j*gr.nx + i
+			x, y := gr.position(c)
+
+			if geo.PointToPolyDist(geom.Coord{x, y}, poly) < margin {
+				fn(c)
+			}
+		}
+	}
+}
+
+// blockPolygon blocks on the layers the cells closer than margin to poly.
+func (gr *grid) blockPolygon(poly *geom.Polygon, margin float64, layers []int) {
+	if len(layers) == 0 {
+		return
+	}
+
+	gr.cellsNear(poly, margin, func(c int) {
+		for _, l := range layers {
+			gr.block(c, l)
+		}
+	})
+}
+
+type state struct {
+	cell  int
+	layer int
+}
+
+type queueItem struct {
+	s        state
+	priority float64
+}
+
+type priorityQueue []queueItem
+
+func (q priorityQueue) Len() int            { return len(q) }
+func (q priorityQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q priorityQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// octile returns the length of the shortest 8-connected path between two cells, in cells.
+func (gr *grid) octile(c1, c2 int) float64 {
+	dx := math.Abs(float64(c1%gr.nx - c2%gr.nx))
+	dy := math.Abs(float64(c1/gr.nx - c2/gr.nx))
+
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+// The 4 orthogonal moves come first
+var moves = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// search returns the cheapest path from start to goal with A*, moving to the 8 neighbouring cells,
+// or the 4 orthogonal ones unless diagonal, or changing layer for viaCost. Diagonal moves can't cut
+// the corner of a blocked cell. The start and goal cells are never blocked.
+func (gr *grid) search(start, goal int, startLayers, goalLayers []int, viaCost float64, diagonal bool) []state {
+	isGoal := make(map[int]bool, len(goalLayers))
+	for _, l := range goalLayers {
+		isGoal[l] = true
+	}
+
+	free := func(s state) bool {
+		return s.cell == start || s.cell == goal || !gr.isBlocked(s.cell, s.layer)
+	}
+
+	cost := make(map[state]float64)
+	parent := make(map[state]state)
+	queue := &priorityQueue{}
+
+	for _, l := range startLayers {
+		s := state{start, l}
+		cost[s] = 0
+		heap.Push(queue, queueItem{s, gr.octile(start, goal)})
+	}
+
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(queueItem)
+		s := item.s
+
+		if item.priority > cost[s]+gr.octile(s.cell, goal)+1e-9 {
+			continue
+		}
+
+		if s.cell == goal && isGoal[s.layer] {
+			path := []state{s}
+			for {
+				p, ok := parent[path[0]]
+				if !ok {
+					break
+				}
+				path = append([]state{p}, path...)
+			}
+
+			return path
+		}
+
+		next := make([]state, 0, len(moves)+gr.layers)
+		steps := make([]float64, 0, len(moves)+gr.layers)
+		i, j := s.cell%gr.nx, s.cell/gr.nx
+
+		allowed := moves
+		if !diagonal {
+			allowed = moves[:4]
+		}
+
+		for _, m := range allowed {
+			ni, nj := i+m[0], j+m[1]
+			if ni < 0 || nj < 0 || ni >= gr.nx || nj >= gr.ny {
+				continue
+			}
+
+			if m[0] != 0 && m[1] != 0 && (!free(state{j*gr.nx + ni, s.layer}) || !free(state{nj*gr.nx + i, s.layer})) {
+				continue
+			}
+
+			next = append(next, state{nj*gr.nx + ni, s.layer})
+			steps = append(steps, math.Hypot(float64(m[0]), float64(m[1])))
+		}
+
+		if !gr.noVia[s.cell] || s.cell == start || s.cell == goal {
+			for l := 0; l < gr.layers; l++ {
+				if l != s.layer {
+					next = append(next, state{s.cell, l})
+					steps = append(steps, viaCost)
+				}
+			}
+		}
+
+		for k, n := range next {
+			if !free(n) {
+				continue
+			}
+
+			c := cost[s] + steps[k]
+			if old, ok := cost[n]; ok && old <= c {
+				continue
+			}
+
+			cost[n] = c
+			parent[n] = s
+			heap.Push(queue, queueItem{n, c + gr.octile(n.cell, goal)})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package router_test
+
+import (
+	"genetic_pcb/drc"
+	"genetic_pcb/geo"
+	"genetic_pcb/pcb"
+	"genetic_pcb/router"
+	"testing"
+
+	"github.com/twpayne/go-geom"
+)
+
+func TestRoute(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost: 1,
+		OutOfBoundsCost:           100,
+		UnconnectedCost:           10,
+		MinDist:                   2,
+	})
+
+	// Two nets crossing on the same plane
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 12, Y: 52, Component: 1},
+			{X: 92, Y: 52, Component: 2},
+			{X: 52, Y: 12, Component: 3},
+			{X: 52, Y: 92, Component: 4},
+		},
+		Edges: []pcb.Edge{
+			{From: 0, To: 1, Net: 0},
+			{From: 2, To: 3, Net: 1},
+		},
+		Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}},
+	})
+
+	if report := drc.Check(pgo, p); report.Count(pcb.SHORT_VIOLATION) != 1 {
+		t.Fatalf("Expected a short before routing, got %v", report.Violations)
+	}
+
+	res := router.New(pgo, router.Params{}).Route(p)
+
+	if res.Rerouted != 2 || res.Failed != 0 {
+		t.Errorf("Expected 2 edges rerouted and none failed, got %+v", res)
+	}
+
+	// Crossings on different planes are allowed, they cost nothing here
+	if report := drc.Check(pgo, p); report.Count(pcb.SHORT_VIOLATION) != 0 || report.Count(pcb.CLEARANCE_VIOLATION) != 0 || report.Cost() != 0 {
+		t.Errorf("Expected no shorts and no clearance violations after routing, got %v", report.Violations)
+	}
+
+	// The nets must still end in their pads
+	for i, n := range p.Genome.Nets {
+		if n.Nodes[0] != 2*i || n.Nodes[1] != 2*i+1 {
+			t.Errorf("Net %d lost its pads: %v", i, n.Nodes)
+		}
+	}
+}
+
+func TestRouteStyles(t *testing.T) {
+	for _, style := range []pcb.RoutingStyle{pcb.ORTHOGONAL_ROUTING, pcb.OCTILINEAR_ROUTING} {
+		pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+			SamePlaneIntersectionCost: 1,
+			UnconnectedCost:           10,
+			MinDist:                   2,
+		})
+		pgo.SetRoutingStyle(style)
+
+		// Two crossing nets, with pads of a third one in the way
+		p := pcb.NewPcb(&pcb.Genome{
+			Nodes: []pcb.Node{
+				{X: 12, Y: 52, Component: 1},
+				{X: 92, Y: 52, Component: 2},
+				{X: 52, Y: 12, Component: 3},
+				{X: 52, Y: 92, Component: 4},
+				{X: 32, Y: 48, Component: 5},
+				{X: 72, Y: 56, Component: 6},
+			},
+			Edges: []pcb.Edge{
+				{From: 0, To: 1, Net: 0},
+				{From: 2, To: 3, Net: 1},
+			},
+			Nets: []pcb.Net{{Nodes: []int{0, 1}}, {Nodes: []int{2, 3}}, {Nodes: []int{4, 5}}},
+		})
+
+		if res := router.New(pgo, router.Params{}).Route(p); res.Failed != 0 {
+			t.Errorf("Style %v: expected every edge rerouted, got %+v", style, res)
+		}
+
+		// Orthogonal edges are only horizontal or vertical, diagonal steps would be drawn as doglegs
+		for _, e := range p.Genome.Edges {
+			from, to := p.Genome.Nodes[e.From], p.Genome.Nodes[e.To]
+			if style == pcb.ORTHOGONAL_ROUTING && from.X != to.X && from.Y != to.Y {
+				t.Errorf("Expected an orthogonal edge, got %v, %v to %v, %v", from.X, from.Y, to.X, to.Y)
+			}
+		}
+
+		if report := drc.Check(pgo, p); report.Count(pcb.SHORT_VIOLATION) != 0 || report.Count(pcb.CLEARANCE_VIOLATION) != 0 {
+			t.Errorf("Style %v: expected no shorts and no clearance violations after routing, got %v", style, report.Violations)
+		}
+	}
+}
+
+func TestRouteViaClearance(t *testing.T) {
+	pgo := pcb.NewPcbGeneticOperators(1, 0, 0, 100, 100, 4, 2, 0, pcb.MutationParams{}, pcb.EvaluationParams{
+		SamePlaneIntersectionCost:      1,
+		DifferentPlaneIntersectionCost: 1,
+		MinDist:                        1,
+	})
+
+	// Long SMD pads across the board, on the top plane at x = 70 and on the bottom one at x = 30:
+	// net 0 has to change plane between them, with vias large and far away from other nets
+	wall := func(node int, x float64, side pcb.BoardSide) pcb.Component {
+		return pcb.Component{Nodes: []pcb.ComponentNode{{Node: node, Type: pcb.SMD_PAD, W: 2, H: 92}}, CX: x, CY: 50, X1: -1, Y1: -1, X2: 1, Y2: 1, Side: side}
+	}
+
+	p := pcb.NewPcb(&pcb.Genome{
+		Nodes: []pcb.Node{
+			{X: 10, Y: 50, Component: 0},
+			{X: 90, Y: 50, Component: 1},
+			{X: 70, Y: 50, Component: 2},
+			{X: 30, Y: 50, Component: 3},
+		},
+		Edges: []pcb.Edge{{From: 0, To: 1, Net: 0}},
+		Nets:  []pcb.Net{{Nodes: []int{0, 1}, Class: 1}, {Nodes: []int{2}}, {Nodes: []int{3}}},
+		Components: []pcb.Component{
+			{Nodes: []pcb.ComponentNode{{Node: 0}}, CX: 10, CY: 50},
+			{Nodes: []pcb.ComponentNode{{Node: 1}}, CX: 90, CY: 50},
+			wall(2, 70, pcb.TOP_SIDE),
+			wall(3, 30, pcb.BOTTOM_SIDE),
+		},
+		NetClasses: []pcb.NetClass{
+			{Name: "default", Width: 2, Clearance: 1, ViaSz: 4},
+			{Name: "power", Width: 2, Clearance: 4, ViaSz: 8},
+		},
+	})
+
+	if res := router.New(pgo, router.Params{CellSize: 1}).Route(p); res.Rerouted != 1 || res.Failed != 0 {
+		t.Fatalf("Expected the edge of net 0 rerouted, got %+v", res)
+	}
+
+	if len(p.Geometry.ViaSites) == 0 {
+		t.Fatal("Expected net 0 to change plane between the pads")
+	}
+
+	// The vias of the class are 8 wide and 4 away from the pads of the other nets
+	for _, v := range p.Geometry.ViaSites {
+		for _, node := range []int{2, 3} {
+			if dist := geo.PointToPolyDist(geom.Coord{v.X, v.Y}, p.Geometry.Nodes[node]) - 4; dist < 4 {
+				t.Errorf("Expected the via at %v, %v 4 away from node %d, got %v", v.X, v.Y, node, dist)
+			}
+		}
+	}
+}